@@ -0,0 +1,455 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidPipeline = errors.New("[Collection] Error: invalid aggregation pipeline")
+)
+
+type StageOp string
+
+const (
+	StageMatch   StageOp = "$match"
+	StageGroup   StageOp = "$group"
+	StageProject StageOp = "$project"
+	StageSort    StageOp = "$sort"
+	StageLimit   StageOp = "$limit"
+	StageUnwind  StageOp = "$unwind"
+	StageLookup  StageOp = "$lookup"
+)
+
+// Stage is one step of an aggregation pipeline, see Collection.Aggregate.
+// Only the fields of its Op are used.
+type Stage struct {
+	Op StageOp
+	// Filter of $match.
+	Filter Filter
+	// By and Accumulators of $group.
+	By           []string
+	Accumulators []Accumulator
+	// Fields of $project, or the array field of $unwind.
+	Fields []string
+	Field  string
+	// Sort keys of $sort and N of $limit.
+	Sort []SortKey
+	N    int
+	// Lookup of $lookup.
+	Lookup LookupSpec
+}
+
+// LookupSpec joins documents of the collection From whose ForeignField
+// equals LocalField, they are stored as an array of objects in As.
+type LookupSpec struct {
+	From         string
+	LocalField   string
+	ForeignField string
+	As           string
+}
+
+type AccumulatorOp string
+
+const (
+	AccCount AccumulatorOp = "$count"
+	AccSum   AccumulatorOp = "$sum"
+	AccAvg   AccumulatorOp = "$avg"
+	AccMin   AccumulatorOp = "$min"
+	AccMax   AccumulatorOp = "$max"
+	AccPush  AccumulatorOp = "$push"
+)
+
+// Accumulator computes the field As of a group from Field of its documents.
+// Sum and avg skip values that are not numbers, min and max skip missing
+// ones and push collects all present values in order.
+type Accumulator struct {
+	Op    AccumulatorOp
+	Field string
+	As    string
+}
+
+// Match keeps the documents matching the filter.
+func Match(filter Filter) Stage { return Stage{Op: StageMatch, Filter: filter} }
+
+// Group builds one document per distinct combination of the by fields, with
+// the by fields and the accumulated ones. They are stored like Project stores
+// fields. Without by fields all documents form one group. Groups keep the
+// order of their first document.
+func Group(by []string, accs ...Accumulator) Stage {
+	return Stage{Op: StageGroup, By: by, Accumulators: accs}
+}
+
+// Project keeps only the given fields. Paths into objects keep their nesting,
+// paths with array indexes become top-level fields named by the path.
+func Project(fields ...string) Stage { return Stage{Op: StageProject, Fields: fields} }
+
+func SortBy(keys ...SortKey) Stage { return Stage{Op: StageSort, Sort: keys} }
+func Limit(n int) Stage            { return Stage{Op: StageLimit, N: n} }
+
+// Unwind replaces a document with one copy per element of the array field,
+// the field holding the element. Documents without elements are dropped.
+func Unwind(field string) Stage { return Stage{Op: StageUnwind, Field: field} }
+
+// Lookup joins documents of another collection of the same Store.
+func Lookup(from, localField, foreignField, as string) Stage {
+	return Stage{Op: StageLookup, Lookup: LookupSpec{From: from, LocalField: localField, ForeignField: foreignField, As: as}}
+}
+
+func Count(as string) Accumulator             { return Accumulator{Op: AccCount, As: as} }
+func Sum(as, field string) Accumulator        { return Accumulator{Op: AccSum, Field: field, As: as} }
+func Avg(as, field string) Accumulator        { return Accumulator{Op: AccAvg, Field: field, As: as} }
+func MinOf(as, field string) Accumulator      { return Accumulator{Op: AccMin, Field: field, As: as} }
+func MaxOf(as, field string) Accumulator      { return Accumulator{Op: AccMax, Field: field, As: as} }
+func PushValues(as, field string) Accumulator { return Accumulator{Op: AccPush, Field: field, As: as} }
+
+func (st Stage) validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidPipeline, st.Op, fmt.Sprintf(format, args...))
+	}
+	switch st.Op {
+	case StageMatch:
+		return st.Filter.Validate()
+	case StageGroup:
+		for _, field := range st.By {
+			if _, err := parsePath(field); err != nil {
+				return invalid("%v", err)
+			}
+		}
+		if len(st.Accumulators) == 0 && len(st.By) == 0 {
+			return invalid("expects group fields or accumulators")
+		}
+		for _, acc := range st.Accumulators {
+			if _, err := parsePath(acc.As); err != nil {
+				return invalid("%s: %v", acc.Op, err)
+			}
+			switch acc.Op {
+			case AccCount:
+			case AccSum, AccAvg, AccMin, AccMax, AccPush:
+				if _, err := parsePath(acc.Field); err != nil {
+					return invalid("%s: %v", acc.Op, err)
+				}
+			default:
+				return invalid("unknown accumulator %q", acc.Op)
+			}
+		}
+	case StageProject:
+		if len(st.Fields) == 0 {
+			return invalid("expects fields")
+		}
+		for _, field := range st.Fields {
+			if _, err := parsePath(field); err != nil {
+				return invalid("%v", err)
+			}
+		}
+	case StageSort:
+		if len(st.Sort) == 0 {
+			return invalid("expects sort keys")
+		}
+		return ListOptions{Sort: st.Sort}.validate()
+	case StageLimit:
+		if st.N < 0 {
+			return invalid("negative limit %d", st.N)
+		}
+	case StageUnwind:
+		if _, err := parsePath(st.Field); err != nil {
+			return invalid("%v", err)
+		}
+	case StageLookup:
+		l := st.Lookup
+		if strings.TrimSpace(l.From) == "" {
+			return invalid("collection is empty")
+		}
+		for _, field := range []string{l.LocalField, l.ForeignField, l.As} {
+			if _, err := parsePath(field); err != nil {
+				return invalid("%v", err)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown stage %q", ErrInvalidPipeline, st.Op)
+	}
+	return nil
+}
+
+// Aggregate runs the stages over the documents of the collection, ordered by
+// primary key, and returns the resulting documents. The collection is not
+// modified. $lookup needs the collection to belong to a Store.
+func (s *Collection) Aggregate(stages ...Stage) ([]Document, error) {
+	for i, st := range stages {
+		if err := st.validate(); err != nil {
+			pkgLogger.Error("[Collection Aggregate] invalid stage", slog.Int("stage", i), slog.Any("error", err))
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+	}
+	docs := s.List()
+	for i, st := range stages {
+		var err error
+		if docs, err = s.runStage(st, docs); err != nil {
+			pkgLogger.Error("[Collection Aggregate] stage failed", slog.Int("stage", i), slog.Any("error", err))
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+	}
+	pkgLogger.Info("[Collection Aggregate] pipeline finished", slog.Int("stages", len(stages)), slog.Int("documents", len(docs)))
+	return docs, nil
+}
+
+func (s *Collection) runStage(st Stage, docs []Document) ([]Document, error) {
+	switch st.Op {
+	case StageMatch:
+		matched := make([]Document, 0, len(docs))
+		for i := range docs {
+			if st.Filter.Match(&docs[i]) {
+				matched = append(matched, docs[i])
+			}
+		}
+		return matched, nil
+	case StageGroup:
+		return groupDocuments(st, docs)
+	case StageProject:
+		projected := make([]Document, len(docs))
+		for i := range docs {
+			projected[i] = Document{Fields: make(map[string]DocumentField, len(st.Fields))}
+			for _, field := range st.Fields {
+				if f, ok := docs[i].GetPath(field); ok {
+					if err := setResultField(&projected[i], field, f); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return projected, nil
+	case StageSort:
+		values := make([][]*DocumentField, len(docs))
+		for i := range docs {
+			values[i] = sortValues(&docs[i], st.Sort)
+		}
+		order := make([]int, len(docs))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return compareSortItems(st.Sort, values[order[a]], "", values[order[b]], "") < 0
+		})
+		sorted := make([]Document, len(docs))
+		for i, j := range order {
+			sorted[i] = docs[j]
+		}
+		return sorted, nil
+	case StageLimit:
+		return docs[:min(st.N, len(docs))], nil
+	case StageUnwind:
+		return unwindDocuments(st.Field, docs)
+	case StageLookup:
+		return s.lookupDocuments(st.Lookup, docs)
+	}
+	return nil, fmt.Errorf("%w: unknown stage %q", ErrInvalidPipeline, st.Op)
+}
+
+type group struct {
+	doc    Document
+	values [][]DocumentField
+}
+
+func groupDocuments(st Stage, docs []Document) ([]Document, error) {
+	var groups []*group
+	byKey := make(map[string]*group)
+	for i := range docs {
+		doc := &docs[i]
+		var key strings.Builder
+		for _, field := range st.By {
+			key.WriteString(groupKey(doc, field))
+			key.WriteByte(0)
+		}
+		g, ok := byKey[key.String()]
+		if !ok {
+			g = &group{doc: Document{Fields: make(map[string]DocumentField)}, values: make([][]DocumentField, len(st.Accumulators))}
+			for _, field := range st.By {
+				if f, ok := doc.GetPath(field); ok {
+					if err := setResultField(&g.doc, field, f); err != nil {
+						return nil, err
+					}
+				}
+			}
+			byKey[key.String()] = g
+			groups = append(groups, g)
+		}
+		for j, acc := range st.Accumulators {
+			if acc.Op == AccCount {
+				g.values[j] = append(g.values[j], DocumentField{})
+			} else if f, ok := doc.GetPath(acc.Field); ok {
+				g.values[j] = append(g.values[j], f)
+			}
+		}
+	}
+
+	result := make([]Document, 0, len(groups))
+	for _, g := range groups {
+		for j, acc := range st.Accumulators {
+			f, err := accumulate(acc, g.values[j])
+			if err != nil {
+				return nil, err
+			}
+			if err := setResultField(&g.doc, acc.As, f); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, g.doc)
+	}
+	return result, nil
+}
+
+// setResultField stores f at path of a document built by a stage. Paths with
+// array indexes cannot be built up from nothing, they name a top-level field.
+func setResultField(doc *Document, path string, f DocumentField) error {
+	if strings.ContainsRune(path, '[') {
+		doc.Fields[path] = f
+		return nil
+	}
+	return doc.SetPath(path, f)
+}
+
+// groupKey encodes the value of field so that equal values get equal keys.
+func groupKey(doc *Document, field string) string {
+	f, ok := doc.GetPath(field)
+	if !ok {
+		return "missing"
+	}
+	if key, ok := hashKey(f); ok {
+		return "h:" + key
+	}
+	data, _ := json.Marshal(f)
+	return "j:" + string(data)
+}
+
+func accumulate(acc Accumulator, values []DocumentField) (DocumentField, error) {
+	switch acc.Op {
+	case AccCount:
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: len(values)}, nil
+	case AccSum, AccAvg:
+		sum := DocumentField{Type: DocumentFieldTypeNumber, Value: 0}
+		n := 0
+		for _, f := range values {
+			if orderType(f.Type) != DocumentFieldTypeNumber {
+				continue
+			}
+			if f.Type == DocumentFieldTypeDecimal && sum.Type != DocumentFieldTypeDecimal {
+				r, ok := toRat(sum.Value)
+				if !ok {
+					return DocumentField{}, fmt.Errorf("%s %s: cannot add %v", acc.Op, acc.Field, sum.Value)
+				}
+				sum = DocumentField{Type: DocumentFieldTypeDecimal, Value: r}
+			}
+			v, err := addNumbers(sum, f.Value)
+			if err != nil {
+				return DocumentField{}, fmt.Errorf("%s %s: %v", acc.Op, acc.Field, err)
+			}
+			sum.Value = v
+			n++
+		}
+		if acc.Op == AccSum {
+			return sum, nil
+		}
+		if n == 0 {
+			return DocumentField{Type: DocumentFieldTypeNull}, nil
+		}
+		if sum.Type == DocumentFieldTypeDecimal {
+			r, _ := toRat(sum.Value)
+			return DocumentField{Type: DocumentFieldTypeDecimal, Value: new(big.Rat).Quo(r, big.NewRat(int64(n), 1))}, nil
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: toFloat(reflect.ValueOf(sum.Value)) / float64(n)}, nil
+	case AccMin, AccMax:
+		var best *DocumentField
+		for i := range values {
+			if best == nil {
+				best = &values[i]
+				continue
+			}
+			c := compareSortValue(&values[i], best)
+			if (acc.Op == AccMin && c < 0) || (acc.Op == AccMax && c > 0) {
+				best = &values[i]
+			}
+		}
+		if best == nil {
+			return DocumentField{Type: DocumentFieldTypeNull}, nil
+		}
+		return *best, nil
+	case AccPush:
+		return DocumentField{Type: DocumentFieldTypeArray, Value: append([]DocumentField{}, values...)}, nil
+	}
+	return DocumentField{}, fmt.Errorf("%w: unknown accumulator %q", ErrInvalidPipeline, acc.Op)
+}
+
+func unwindDocuments(field string, docs []Document) ([]Document, error) {
+	result := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		f, ok := doc.GetPath(field)
+		if !ok {
+			continue
+		}
+		if f.Type != DocumentFieldTypeArray {
+			result = append(result, doc)
+			continue
+		}
+		elems, ok := arrayFields(f)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s holds unsupported elements", ErrInvalidPath, field)
+		}
+		for _, elem := range elems {
+			unwound := Document{Fields: make(map[string]DocumentField, len(doc.Fields)), Revision: doc.Revision}
+			for name, f := range doc.Fields {
+				unwound.Fields[name] = f
+			}
+			if err := unwound.SetPath(field, elem); err != nil {
+				return nil, err
+			}
+			result = append(result, unwound)
+		}
+	}
+	return result, nil
+}
+
+func (s *Collection) lookupDocuments(l LookupSpec, docs []Document) ([]Document, error) {
+	if s.store == nil {
+		return nil, fmt.Errorf("%w: $lookup needs a collection of a Store", ErrInvalidPipeline)
+	}
+	from, err := s.store.GetCollection(l.From)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Document, len(docs))
+	for i, doc := range docs {
+		joined := make([]DocumentField, 0)
+		if local, ok := doc.GetPath(l.LocalField); ok {
+			matches, err := from.Find(Eq(l.ForeignField, local))
+			if err != nil {
+				return nil, err
+			}
+			sort.Slice(matches, func(a, b int) bool {
+				return from.keyOf(&matches[a]) < from.keyOf(&matches[b])
+			})
+			for _, m := range matches {
+				joined = append(joined, DocumentField{Type: DocumentFieldTypeObject, Value: m.Fields})
+			}
+		}
+		result[i] = Document{Fields: make(map[string]DocumentField, len(doc.Fields)+1), Revision: doc.Revision}
+		for name, f := range doc.Fields {
+			result[i].Fields[name] = f
+		}
+		if err := result[i].SetPath(l.As, DocumentField{Type: DocumentFieldTypeArray, Value: joined}); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// keyOf returns the primary key of a stored document.
+func (s *Collection) keyOf(doc *Document) string {
+	key, _ := doc.Fields[s.cfg.PrimaryKey].Value.(string)
+	return key
+}
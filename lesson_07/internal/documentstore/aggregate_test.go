@@ -0,0 +1,166 @@
+package documentstore
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func orderDoc(id, customer, status string, total any, items ...string) Document {
+	totalField, _ := fieldFromValue(total)
+	itemFields := make([]DocumentField, len(items))
+	for i, item := range items {
+		itemFields[i] = DocumentField{Type: DocumentFieldTypeString, Value: item}
+	}
+	return Document{Fields: map[string]DocumentField{
+		"id":       {Type: DocumentFieldTypeString, Value: id},
+		"customer": {Type: DocumentFieldTypeString, Value: customer},
+		"status":   {Type: DocumentFieldTypeString, Value: status},
+		"total":    totalField,
+		"items":    {Type: DocumentFieldTypeArray, Value: itemFields},
+	}}
+}
+
+func ordersStore(t *testing.T) (*Store, *Collection) {
+	t.Helper()
+	s := NewStore()
+	orders, err := s.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	for _, doc := range []Document{
+		orderDoc("o1", "c1", "paid", 10, "apple", "pear"),
+		orderDoc("o2", "c2", "paid", 5.5, "apple"),
+		orderDoc("o3", "c1", "new", 7),
+		orderDoc("o4", "c1", "paid", 20, "plum"),
+	} {
+		require.NoError(t, orders.Put(doc))
+	}
+	customers, err := s.CreateCollection("customers", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, customers.Put(userFields("c1", map[string]any{"name": "Ann"})))
+	require.NoError(t, customers.Put(userFields("c2", map[string]any{"name": "Bob"})))
+	return s, orders
+}
+
+func field(t *testing.T, doc Document, path string) any {
+	t.Helper()
+	f, ok := doc.GetPath(path)
+	require.True(t, ok, path)
+	return f.Value
+}
+
+func TestAggregate_Group(t *testing.T) {
+	_, orders := ordersStore(t)
+	res, err := orders.Aggregate(
+		Match(Eq("status", "paid")),
+		Group([]string{"customer"},
+			Count("orders"), Sum("total", "total"), Avg("avg", "total"),
+			MinOf("min", "total"), MaxOf("max", "total"), PushValues("ids", "id")),
+		SortBy(SortKey{Field: "total", Order: SortDesc}),
+	)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+
+	c1 := res[0]
+	assert.Equal(t, "c1", field(t, c1, "customer"))
+	assert.Equal(t, 2, field(t, c1, "orders"))
+	assert.Equal(t, 30, field(t, c1, "total"))
+	assert.Equal(t, 15.0, field(t, c1, "avg"))
+	assert.Equal(t, 10, field(t, c1, "min"))
+	assert.Equal(t, 20, field(t, c1, "max"))
+	assert.Equal(t, []DocumentField{
+		{Type: DocumentFieldTypeString, Value: "o1"},
+		{Type: DocumentFieldTypeString, Value: "o4"},
+	}, field(t, c1, "ids"))
+	assert.Equal(t, 5.5, field(t, res[1], "total"))
+
+	t.Run("without group fields", func(t *testing.T) {
+		res, err := orders.Aggregate(Group(nil, Count("n"), Sum("total", "total")))
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, 4, field(t, res[0], "n"))
+		assert.Equal(t, 42.5, field(t, res[0], "total"))
+	})
+
+	t.Run("decimals stay exact", func(t *testing.T) {
+		prices, err := NewStore().CreateCollection("prices", &CollectionConfig{PrimaryKey: "id"})
+		require.NoError(t, err)
+		require.NoError(t, prices.Put(orderDoc("p1", "c1", "", big.NewRat(1, 10))))
+		require.NoError(t, prices.Put(orderDoc("p2", "c1", "", 1)))
+		res, err := prices.Aggregate(Group(nil, Sum("sum", "total"), Avg("avg", "total")))
+		require.NoError(t, err)
+		assert.Equal(t, big.NewRat(11, 10), field(t, res[0], "sum"))
+		assert.Equal(t, big.NewRat(11, 20), field(t, res[0], "avg"))
+	})
+}
+
+func TestAggregate_UnwindProjectLimit(t *testing.T) {
+	_, orders := ordersStore(t)
+	res, err := orders.Aggregate(
+		Unwind("items"),
+		Group([]string{"items"}, Count("n")),
+		SortBy(SortKey{Field: "n", Order: SortDesc}, SortKey{Field: "items"}),
+		Limit(2),
+	)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "apple", field(t, res[0], "items"))
+	assert.Equal(t, 2, field(t, res[0], "n"))
+	assert.Equal(t, "pear", field(t, res[1], "items"))
+
+	res, err = orders.Aggregate(Match(Eq("id", "o1")), Project("id", "items[1]"))
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	assert.Equal(t, Document{Fields: map[string]DocumentField{
+		"id":       {Type: DocumentFieldTypeString, Value: "o1"},
+		"items[1]": {Type: DocumentFieldTypeString, Value: "pear"},
+	}}, res[0])
+	_, ok := res[0].Fields["status"]
+	assert.False(t, ok)
+}
+
+func TestAggregate_Lookup(t *testing.T) {
+	_, orders := ordersStore(t)
+	res, err := orders.Aggregate(
+		Match(Eq("status", "paid")),
+		Lookup("customers", "customer", "id", "buyer"),
+		Unwind("buyer"),
+		Project("id", "buyer.name"),
+	)
+	require.NoError(t, err)
+	names := make(map[string]any)
+	for _, doc := range res {
+		names[field(t, doc, "id").(string)] = field(t, doc, "buyer.name")
+	}
+	assert.Equal(t, map[string]any{"o1": "Ann", "o2": "Bob", "o4": "Ann"}, names)
+
+	_, err = orders.Aggregate(Lookup("missing", "customer", "id", "buyer"))
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+
+	standalone := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+	_, err = standalone.Aggregate(Lookup("customers", "customer", "id", "buyer"))
+	assert.ErrorIs(t, err, ErrInvalidPipeline)
+}
+
+func TestAggregate_InvalidStages(t *testing.T) {
+	_, orders := ordersStore(t)
+	for name, st := range map[string]Stage{
+		"unknown stage":       {Op: "$out"},
+		"bad filter":          Match(Filter{Op: "$like"}),
+		"empty group":         Group(nil),
+		"unknown accumulator": Group(nil, Accumulator{Op: "$first", Field: "a", As: "b"}),
+		"accumulator field":   Group(nil, Sum("total", "")),
+		"empty project":       Project(),
+		"empty sort":          SortBy(),
+		"negative limit":      Limit(-1),
+		"unwind path":         Unwind("a..b"),
+		"lookup from":         Lookup("", "a", "b", "c"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := orders.Aggregate(Match(Eq("status", "paid")), st)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "stage 1")
+		})
+	}
+}
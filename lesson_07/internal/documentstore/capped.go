@@ -0,0 +1,195 @@
+package documentstore
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+var (
+	ErrQuotaExceeded = errors.New("[Collection] Error: collection quota exceeded")
+	ErrInvalidCap    = errors.New("[Collection] Error: invalid collection limits")
+)
+
+// CapPolicy tells what happens when a write would take a collection over
+// CollectionConfig.MaxDocuments or MaxBytes.
+type CapPolicy string
+
+const (
+	// CapPolicyEvict removes the oldest documents, in insertion order, until
+	// the new one fits, like a capped log. Replacing a document keeps its place.
+	CapPolicyEvict CapPolicy = "evict"
+	// CapPolicyReject rejects the write with a QuotaError.
+	CapPolicyReject CapPolicy = "reject"
+)
+
+// QuotaError is returned when a write does not fit in the limits of a capped
+// collection. Limit is "documents" or "bytes", Actual is what the collection
+// would hold after the write.
+type QuotaError struct {
+	Key    string
+	Limit  string
+	Max    int64
+	Actual int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: document '%s' would make it %d %s, limit is %d",
+		ErrQuotaExceeded.Error(), e.Key, e.Actual, e.Limit, e.Max)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+func validateCap(cfg *CollectionConfig) error {
+	if cfg.MaxDocuments < 0 || cfg.MaxBytes < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidCap)
+	}
+	switch cfg.CapPolicy {
+	case "", CapPolicyEvict, CapPolicyReject:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown policy %q", ErrInvalidCap, cfg.CapPolicy)
+}
+
+// capState keeps the keys of a capped collection in insertion order
+// with the sizes of their documents.
+type capState struct {
+	order   *list.List
+	entries map[string]*capEntry
+	bytes   int64
+	// sequence numbers the insertions, so a rolled back removal can put
+	// the document back in its place.
+	sequence uint64
+}
+
+type capEntry struct {
+	elem *list.Element
+	size int64
+	seq  uint64
+}
+
+func newCapState() *capState {
+	return &capState{order: list.New(), entries: make(map[string]*capEntry)}
+}
+
+// documentSize is the size of the JSON encoding of the document fields,
+// the measure of CollectionConfig.MaxBytes.
+func documentSize(doc *Document) int64 {
+	data, err := json.Marshal(doc.Fields)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+func (c *capState) track(key string, doc *Document) {
+	size := documentSize(doc)
+	if e, ok := c.entries[key]; ok {
+		c.bytes += size - e.size
+		e.size = size
+		return
+	}
+	c.sequence++
+	c.entries[key] = &capEntry{elem: c.order.PushBack(key), size: size, seq: c.sequence}
+	c.bytes += size
+}
+
+// place moves a tracked key back to the position of its insertion sequence seq.
+func (c *capState) place(key string, seq uint64) {
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	e.seq = seq
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		if c.entries[elem.Value.(string)].seq > seq {
+			c.order.MoveBefore(e.elem, elem)
+			return
+		}
+	}
+	c.order.MoveToBack(e.elem)
+}
+
+func (c *capState) untrack(key string) {
+	if e, ok := c.entries[key]; ok {
+		c.order.Remove(e.elem)
+		c.bytes -= e.size
+		delete(c.entries, key)
+	}
+}
+
+// capLocked returns the oldest documents to evict to make room for doc stored
+// under key, or a QuotaError. Nothing is evicted yet, so the write can still
+// fail before evictLocked removes them. Must be called with the write lock held.
+func (s *Collection) capLocked(key string, doc *Document) ([]string, error) {
+	if s.capped == nil {
+		return nil, nil
+	}
+	maxDocs, maxBytes := int64(s.cfg.MaxDocuments), s.cfg.MaxBytes
+	size := documentSize(doc)
+	if maxBytes > 0 && size > maxBytes {
+		return nil, &QuotaError{Key: key, Limit: "bytes", Max: maxBytes, Actual: size}
+	}
+	count, bytes := int64(len(s.documents)), s.capped.bytes+size
+	if e, ok := s.capped.entries[key]; ok {
+		bytes -= e.size
+	} else {
+		count++
+	}
+	over := func() *QuotaError {
+		if maxDocs > 0 && count > maxDocs {
+			return &QuotaError{Key: key, Limit: "documents", Max: maxDocs, Actual: count}
+		}
+		if maxBytes > 0 && bytes > maxBytes {
+			return &QuotaError{Key: key, Limit: "bytes", Max: maxBytes, Actual: bytes}
+		}
+		return nil
+	}
+	qerr := over()
+	if qerr == nil {
+		return nil, nil
+	}
+	if s.cfg.CapPolicy == CapPolicyReject {
+		return nil, qerr
+	}
+	var victims []string
+	for elem := s.capped.order.Front(); elem != nil && over() != nil; elem = elem.Next() {
+		victim := elem.Value.(string)
+		if victim != key {
+			victims = append(victims, victim)
+			count--
+			bytes -= s.capped.entries[victim].size
+		}
+	}
+	return victims, nil
+}
+
+// evictLocked removes the documents chosen by capLocked, after the write that
+// evicts them has been logged together with their deletion.
+func (s *Collection) evictLocked(victims []string) {
+	for _, victim := range victims {
+		prev, seq := s.documents[victim], s.capSeqLocked(victim)
+		s.removeLocked(victim)
+		if s.undoBatch != nil {
+			*s.undoBatch = append(*s.undoBatch, txUndo{coll: s, key: victim, prev: prev, existed: true, capSeq: seq})
+		}
+		s.notifyLocked(ChangeEvent{Type: ChangeEvict, Key: victim, Before: prev})
+		pkgLogger.Info("[Collection] document evicted", slog.String("key", victim))
+	}
+}
+
+// capSeqLocked returns the insertion sequence of key in a capped collection,
+// 0 when the collection is not capped or does not hold key.
+func (s *Collection) capSeqLocked(key string) uint64 {
+	if s.capped == nil {
+		return 0
+	}
+	if e, ok := s.capped.entries[key]; ok {
+		return e.seq
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func eventDoc(id, payload string) Document {
+	return Document{Fields: map[string]DocumentField{
+		"id":      {Type: DocumentFieldTypeString, Value: id},
+		"payload": {Type: DocumentFieldTypeString, Value: payload},
+	}}
+}
+
+func TestCapped_Evict(t *testing.T) {
+	s := NewStore()
+	events, err := s.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 3})
+	require.NoError(t, err)
+	ch, err := events.Watch(t.Context(), WatchOptions{})
+	require.NoError(t, err)
+
+	for _, id := range []string{"e3", "e1", "e2"} {
+		require.NoError(t, events.Put(eventDoc(id, "")))
+	}
+	require.NoError(t, events.Put(eventDoc("e3", "replaced")), "replacing keeps the place")
+	require.NoError(t, events.Put(eventDoc("e4", "")))
+	assert.Equal(t, []string{"e1", "e2", "e4"}, ids(events.List()))
+	require.NoError(t, events.Put(eventDoc("e5", "")))
+	assert.Equal(t, []string{"e2", "e4", "e5"}, ids(events.List()))
+
+	changes, _ := drain(ch)
+	var evicted []string
+	for _, ev := range changes {
+		if ev.Type == ChangeEvict {
+			evicted = append(evicted, ev.Key)
+		}
+	}
+	assert.Equal(t, []string{"e3", "e1"}, evicted)
+
+	require.NoError(t, events.Delete("e4"))
+	require.NoError(t, events.Put(eventDoc("e6", "")))
+	assert.Equal(t, []string{"e2", "e5", "e6"}, ids(events.List()), "a free slot is used without eviction")
+}
+
+func TestCapped_MaxBytes(t *testing.T) {
+	size := documentSize(&Document{Fields: eventDoc("e1", "xxxx").Fields})
+	s := NewStore()
+	events, err := s.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", MaxBytes: 2*size + 1})
+	require.NoError(t, err)
+	for _, id := range []string{"e1", "e2"} {
+		require.NoError(t, events.Put(eventDoc(id, "xxxx")))
+	}
+	require.NoError(t, events.Put(eventDoc("e3", "xxxxxxxx")))
+	assert.Equal(t, []string{"e3"}, ids(events.List()), "both old documents make room")
+
+	err = events.Put(eventDoc("big", strings.Repeat("x", int(2*size))))
+	var qerr *QuotaError
+	require.ErrorAs(t, err, &qerr)
+	assert.Equal(t, "bytes", qerr.Limit)
+	assert.Equal(t, []string{"e3"}, ids(events.List()), "nothing is evicted for a document that never fits")
+}
+
+func TestCapped_Reject(t *testing.T) {
+	s := NewStore()
+	audit, err := s.CreateCollection("audit", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 2, CapPolicy: CapPolicyReject})
+	require.NoError(t, err)
+	require.NoError(t, audit.Put(eventDoc("a1", "")))
+	require.NoError(t, audit.Put(eventDoc("a2", "")))
+
+	err = audit.Put(eventDoc("a3", ""))
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	var qerr *QuotaError
+	require.ErrorAs(t, err, &qerr)
+	assert.Equal(t, QuotaError{Key: "a3", Limit: "documents", Max: 2, Actual: 3}, *qerr)
+	assert.NoError(t, audit.Put(eventDoc("a2", "replaced")), "replacing does not grow the collection")
+	assert.Equal(t, []string{"a1", "a2"}, ids(audit.List()))
+}
+
+func TestCapped_TxRollback(t *testing.T) {
+	s := NewStore()
+	events, err := s.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 3})
+	require.NoError(t, err)
+	_, err = s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Unique: []UniqueConstraint{{Fields: []string{"email"}}}})
+	require.NoError(t, err)
+	for _, id := range []string{"e1", "e2", "e3"} {
+		require.NoError(t, events.Put(eventDoc(id, "")))
+	}
+
+	err = s.Tx(func(tx *Tx) error {
+		if err := tx.Delete("events", "e2"); err != nil {
+			return err
+		}
+		for _, id := range []string{"e4", "e5"} {
+			if err := tx.Put("events", eventDoc(id, "")); err != nil {
+				return err
+			}
+		}
+		u1, u2 := userFields("u1", nil), userFields("u2", nil)
+		u2.Fields["email"] = u1.Fields["email"]
+		if err := tx.Put("users", u1); err != nil {
+			return err
+		}
+		return tx.Put("users", u2)
+	})
+	require.ErrorIs(t, err, ErrUniqueViolation)
+	assert.Equal(t, []string{"e1", "e2", "e3"}, ids(events.List()), "deleted and evicted documents are restored")
+
+	require.NoError(t, events.Put(eventDoc("e6", "")))
+	assert.Equal(t, []string{"e2", "e3", "e6"}, ids(events.List()), "restored documents keep their place")
+	require.NoError(t, events.Put(eventDoc("e7", "")))
+	assert.Equal(t, []string{"e3", "e6", "e7"}, ids(events.List()))
+}
+
+func TestDump_KeepsInsertionOrder(t *testing.T) {
+	s := NewStore()
+	events, err := s.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 3})
+	require.NoError(t, err)
+	for _, id := range []string{"c", "a", "b"} {
+		require.NoError(t, events.Put(eventDoc(id, "")))
+	}
+	dump, err := s.Dump()
+	require.NoError(t, err)
+
+	restored, err := NewStoreFromDump(dump)
+	require.NoError(t, err)
+	events, err = restored.GetCollection("events")
+	require.NoError(t, err)
+	require.NoError(t, events.Put(eventDoc("d", "")))
+	assert.Equal(t, []string{"a", "b", "d"}, ids(events.List()))
+}
+
+func TestOpenStore_ReplaysEvictions(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+	s, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	events, err := s.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 2})
+	require.NoError(t, err)
+	for _, id := range []string{"e1", "e2", "e3"} {
+		require.NoError(t, events.Put(eventDoc(id, "")))
+	}
+	require.NoError(t, s.Close())
+
+	reopened, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	defer reopened.Close()
+	events, err = reopened.GetCollection("events")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"e2", "e3"}, ids(events.List()))
+}
+
+func TestCapped_LogsEvictionsWithWrite(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+	s, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	events, err := s.CreateCollection("events", &CollectionConfig{PrimaryKey: "id", MaxDocuments: 2})
+	require.NoError(t, err)
+	for _, id := range []string{"e1", "e2", "e3"} {
+		require.NoError(t, events.Put(eventDoc(id, "")))
+	}
+
+	data, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	var last walRecord
+	require.NoError(t, json.Unmarshal(lines[len(lines)-1], &last))
+	require.Equal(t, walOpTx, last.Op, "the eviction is logged with the put")
+	require.Len(t, last.Records, 2)
+	assert.Equal(t, walRecord{Op: walOpDelete, Collection: "events", Key: "e1"}, last.Records[0])
+	assert.Equal(t, walOpPut, last.Records[1].Op)
+
+	ch, err := events.Watch(t.Context(), WatchOptions{})
+	require.NoError(t, err)
+	require.NoError(t, s.wal.file.Close())
+	require.Error(t, events.Put(eventDoc("e4", "")))
+	assert.Equal(t, []string{"e2", "e3"}, ids(events.List()))
+	changes, _ := drain(ch)
+	assert.Empty(t, changes)
+}
+
+func TestCreateCollection_InvalidCap(t *testing.T) {
+	for name, cfg := range map[string]CollectionConfig{
+		"negative count": {PrimaryKey: "id", MaxDocuments: -1},
+		"negative bytes": {PrimaryKey: "id", MaxBytes: -1},
+		"unknown policy": {PrimaryKey: "id", MaxDocuments: 1, CapPolicy: "drop"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewStore().CreateCollection("c", &cfg)
+			assert.ErrorIs(t, err, ErrInvalidCap)
+		})
+	}
+}
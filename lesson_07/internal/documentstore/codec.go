@@ -0,0 +1,459 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDocumentFieldTypeMismatch = errors.New("document field value does not match its type")
+)
+
+// DocumentField values are stored in dumps and the wal in a canonical form
+// and decoded into these Go values:
+//
+//	string    -> string
+//	number    -> int64, or float64 for floating point values
+//	bool      -> bool
+//	array     -> []DocumentField
+//	object    -> map[string]DocumentField
+//	timestamp -> time.Time, written as an RFC3339 string with nanoseconds
+//	binary    -> []byte, written as a base64 string
+//	decimal   -> *big.Rat, written as a decimal string
+//	null      -> nil
+//
+// Floating point numbers are always written with a fraction or an exponent
+// (1.0, not 1), which is how the decoder tells them apart from integers.
+// Elements of arrays and objects are written as plain JSON values, their
+// types are inferred when decoding. Nested timestamps, binaries and decimals
+// are written as {"$timestamp": "..."}, {"$binary": "..."} and {"$decimal": "..."}.
+
+type documentFieldJSON struct {
+	Type  DocumentFieldType
+	Value json.RawMessage
+}
+
+func (f DocumentField) MarshalJSON() ([]byte, error) {
+	value, err := canonicalFieldValue(f.Type, f.Value)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(encodeCanonical(value, false))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(documentFieldJSON{Type: f.Type, Value: data})
+}
+
+func (f *DocumentField) UnmarshalJSON(data []byte) error {
+	var raw documentFieldJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var value any
+	if len(raw.Value) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(raw.Value))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+	}
+	decoded, err := decodeFieldValue(raw.Type, value)
+	if err != nil {
+		return err
+	}
+	f.Type = raw.Type
+	f.Value = decoded
+	return nil
+}
+
+// validateFields checks that every field value matches its type, so that
+// stored documents can always be written to the wal and dumps.
+func validateFields(doc *Document) error {
+	for name, f := range doc.Fields {
+		if _, err := canonicalFieldValue(f.Type, f.Value); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// canonicalFieldValue converts v to the canonical representation of type t.
+// nil is allowed only for arrays, objects, binary and null.
+func canonicalFieldValue(t DocumentFieldType, v any) (any, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Interface || rv.Kind() == reflect.Pointer && rv.Type() != reflect.PointerTo(ratType) {
+		if rv.IsNil() {
+			rv = reflect.Value{}
+			break
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || ((rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map || rv.Kind() == reflect.Pointer) && rv.IsNil()) {
+		switch t {
+		case DocumentFieldTypeArray, DocumentFieldTypeObject, DocumentFieldTypeBinary, DocumentFieldTypeNull:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %s field has no value", ErrDocumentFieldTypeMismatch, t)
+	}
+	mismatch := fmt.Errorf("%w: %s field holds %s", ErrDocumentFieldTypeMismatch, t, rv.Type())
+	switch t {
+	case DocumentFieldTypeNull:
+		return nil, mismatch
+	case DocumentFieldTypeTimestamp:
+		if rv.Type() != timeType {
+			return nil, mismatch
+		}
+		return rv.Interface().(time.Time), nil
+	case DocumentFieldTypeBinary:
+		if !isBytesType(rv.Type()) {
+			return nil, mismatch
+		}
+		return bytes.Clone(rv.Bytes()), nil
+	case DocumentFieldTypeDecimal:
+		if rv.Type() != ratType && rv.Type() != reflect.PointerTo(ratType) {
+			return nil, mismatch
+		}
+		r, _ := toRat(rv.Interface())
+		if _, ok := decimalString(r); !ok {
+			return nil, fmt.Errorf("%w: %s has no finite decimal representation", ErrUnsupportedDocumentField, r)
+		}
+		return r, nil
+	case DocumentFieldTypeObject:
+		// structs with custom marshalers may convert themselves into objects
+		if f, ok, err := marshalCustom(rv); ok {
+			if err != nil {
+				return nil, err
+			}
+			if f.Type != DocumentFieldTypeObject {
+				return nil, mismatch
+			}
+			return canonicalFieldValue(f.Type, f.Value)
+		}
+	case DocumentFieldTypeString, DocumentFieldTypeNumber, DocumentFieldTypeBool, DocumentFieldTypeArray:
+	default:
+		return nil, fmt.Errorf("%w: unknown field type %q", ErrUnsupportedDocumentField, t)
+	}
+	actual, ok := kindFieldType(rv.Kind())
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedDocumentField, rv.Type())
+	}
+	if actual != t {
+		return nil, mismatch
+	}
+	return canonicalKind(rv)
+}
+
+// canonicalValue converts a nested value, keeping the type MarshalDocument
+// would give it.
+func canonicalValue(rv reflect.Value) (any, error) {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Type() == documentFieldType {
+		f := rv.Interface().(DocumentField)
+		return canonicalFieldValue(f.Type, f.Value)
+	}
+	if f, ok, err := marshalCustom(rv); ok {
+		if err != nil {
+			return nil, err
+		}
+		return canonicalFieldValue(f.Type, f.Value)
+	}
+	return canonicalKind(rv)
+}
+
+// canonicalKind converts rv by its kind, elements are converted by canonicalValue.
+func canonicalKind(rv reflect.Value) (any, error) {
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%w: %d overflows int64", ErrUnsupportedDocumentField, rv.Uint())
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%w: %v is not a valid number", ErrUnsupportedDocumentField, f)
+		}
+		return f, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			elem, err := canonicalValue(rv.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = elem
+		}
+		return out, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: map key %s", ErrUnsupportedDocumentField, rv.Type().Key())
+		}
+		if rv.IsNil() {
+			return nil, nil
+		}
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			if strings.HasPrefix(iter.Key().String(), "$") {
+				// keys like timestampKey mark wrapped values when decoding
+				return nil, fmt.Errorf("%w: object key %q starts with $", ErrUnsupportedDocumentField, iter.Key().String())
+			}
+			elem, err := canonicalValue(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", iter.Key().String(), err)
+			}
+			out[iter.Key().String()] = elem
+		}
+		return out, nil
+	case reflect.Struct:
+		// structs are mapped by their tags exactly like MarshalDocument does
+		fields, err := marshalStruct(rv)
+		if err != nil {
+			return nil, err
+		}
+		return canonicalFieldValue(DocumentFieldTypeObject, fields)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedDocumentField, rv.Type())
+}
+
+// Nested timestamps, binaries and decimals cannot be told apart from strings
+// by their JSON value, they are wrapped in single-key objects. Object keys
+// starting with $ are rejected, so user objects never look like them.
+const (
+	timestampKey = "$timestamp"
+	binaryKey    = "$binary"
+	decimalKey   = "$decimal"
+)
+
+// encodeCanonical replaces float64 values with number literals that keep
+// a fraction or an exponent and formats timestamps, binaries and decimals.
+// nested values are wrapped, see timestampKey.
+func encodeCanonical(v any, nested bool) any {
+	wrap := func(key, s string) any {
+		if nested {
+			return map[string]any{key: s}
+		}
+		return s
+	}
+	switch v := v.(type) {
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eE") {
+			s += ".0"
+		}
+		return json.Number(s)
+	case time.Time:
+		return wrap(timestampKey, v.Format(time.RFC3339Nano))
+	case []byte:
+		return wrap(binaryKey, base64.StdEncoding.EncodeToString(v))
+	case *big.Rat:
+		s, _ := decimalString(v)
+		return wrap(decimalKey, s)
+	case []any:
+		out := make([]any, len(v))
+		for i, elem := range v {
+			out[i] = encodeCanonical(elem, true)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, elem := range v {
+			out[k] = encodeCanonical(elem, true)
+		}
+		return out
+	}
+	return v
+}
+
+// decodeFieldValue converts a value decoded with json.Decoder.UseNumber into
+// the canonical representation of type t.
+func decodeFieldValue(t DocumentFieldType, v any) (any, error) {
+	mismatch := func() error {
+		return fmt.Errorf("%w: %s field holds %s", ErrDocumentFieldTypeMismatch, t, jsonTypeName(v))
+	}
+	switch t {
+	case DocumentFieldTypeString:
+		if _, ok := v.(string); !ok {
+			return nil, mismatch()
+		}
+	case DocumentFieldTypeBool:
+		if _, ok := v.(bool); !ok {
+			return nil, mismatch()
+		}
+	case DocumentFieldTypeNumber:
+		if _, ok := v.(json.Number); !ok {
+			return nil, mismatch()
+		}
+	case DocumentFieldTypeArray:
+		if _, ok := v.([]any); !ok && v != nil {
+			return nil, mismatch()
+		}
+	case DocumentFieldTypeObject:
+		if _, ok := v.(map[string]any); !ok && v != nil {
+			return nil, mismatch()
+		}
+	case DocumentFieldTypeNull:
+		if v != nil {
+			return nil, mismatch()
+		}
+	case DocumentFieldTypeTimestamp, DocumentFieldTypeBinary, DocumentFieldTypeDecimal:
+		if v == nil && t == DocumentFieldTypeBinary {
+			return nil, nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, mismatch()
+		}
+		return parseWrapped(t, s)
+	default:
+		return nil, fmt.Errorf("%w: unknown field type %q", ErrUnsupportedDocumentField, t)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	f, err := inferField(v)
+	if err != nil {
+		return nil, err
+	}
+	return f.Value, nil
+}
+
+// parseWrapped parses the string form of a timestamp, binary or decimal.
+func parseWrapped(t DocumentFieldType, s string) (any, error) {
+	switch t {
+	case DocumentFieldTypeTimestamp:
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid timestamp %q", ErrDocumentFieldTypeMismatch, s)
+		}
+		return ts, nil
+	case DocumentFieldTypeBinary:
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid base64 data", ErrDocumentFieldTypeMismatch)
+		}
+		return data, nil
+	}
+	return parseDecimal(s)
+}
+
+// inferField builds a field from a JSON value, taking the type from JSON.
+func inferField(v any) (DocumentField, error) {
+	switch v := v.(type) {
+	case nil:
+		return DocumentField{Type: DocumentFieldTypeNull}, nil
+	case string:
+		return DocumentField{Type: DocumentFieldTypeString, Value: v}, nil
+	case bool:
+		return DocumentField{Type: DocumentFieldTypeBool, Value: v}, nil
+	case json.Number:
+		n, err := parseNumber(string(v))
+		if err != nil {
+			return DocumentField{}, err
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: n}, nil
+	case []any:
+		elems := make([]DocumentField, len(v))
+		for i, elem := range v {
+			f, err := inferField(elem)
+			if err != nil {
+				return DocumentField{}, err
+			}
+			elems[i] = f
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: elems}, nil
+	case map[string]any:
+		if t, s, ok := unwrapValue(v); ok {
+			value, err := parseWrapped(t, s)
+			if err != nil {
+				return DocumentField{}, err
+			}
+			return DocumentField{Type: t, Value: value}, nil
+		}
+		fields := make(map[string]DocumentField, len(v))
+		for k, elem := range v {
+			f, err := inferField(elem)
+			if err != nil {
+				return DocumentField{}, err
+			}
+			fields[k] = f
+		}
+		return DocumentField{Type: DocumentFieldTypeObject, Value: fields}, nil
+	}
+	return DocumentField{}, fmt.Errorf("%w: %T", ErrUnsupportedDocumentField, v)
+}
+
+// unwrapValue recognizes the objects written by encodeCanonical for nested
+// timestamps, binaries and decimals.
+func unwrapValue(m map[string]any) (DocumentFieldType, string, bool) {
+	if len(m) != 1 {
+		return "", "", false
+	}
+	for key, t := range map[string]DocumentFieldType{
+		timestampKey: DocumentFieldTypeTimestamp,
+		binaryKey:    DocumentFieldTypeBinary,
+		decimalKey:   DocumentFieldTypeDecimal,
+	} {
+		if s, ok := m[key].(string); ok {
+			return t, s, true
+		}
+	}
+	return "", "", false
+}
+
+// parseNumber returns int64 for integer literals and float64 otherwise.
+// Integers that do not fit int64 fall back to float64.
+func parseNumber(s string) (any, error) {
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid number %s", ErrDocumentFieldTypeMismatch, s)
+	}
+	return f, nil
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case json.Number:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,187 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentField_JSONRoundTrip(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+		Zip  int
+	}
+	tests := []struct {
+		name  string
+		field DocumentField
+		want  any
+	}{
+		{"string", DocumentField{Type: DocumentFieldTypeString, Value: "alice"}, "alice"},
+		{"int", DocumentField{Type: DocumentFieldTypeNumber, Value: 42}, int64(42)},
+		{"uint8", DocumentField{Type: DocumentFieldTypeNumber, Value: uint8(7)}, int64(7)},
+		{"integral float", DocumentField{Type: DocumentFieldTypeNumber, Value: 3.0}, 3.0},
+		{"float", DocumentField{Type: DocumentFieldTypeNumber, Value: float32(1.5)}, 1.5},
+		{"large float", DocumentField{Type: DocumentFieldTypeNumber, Value: 1e21}, 1e21},
+		{"bool", DocumentField{Type: DocumentFieldTypeBool, Value: true}, true},
+		{"array", DocumentField{Type: DocumentFieldTypeArray, Value: []int{1, 2}},
+			[]DocumentField{{Type: DocumentFieldTypeNumber, Value: int64(1)}, {Type: DocumentFieldTypeNumber, Value: int64(2)}}},
+		{"mixed array", DocumentField{Type: DocumentFieldTypeArray, Value: []any{"a", 1.0, false, nil}},
+			[]DocumentField{
+				{Type: DocumentFieldTypeString, Value: "a"},
+				{Type: DocumentFieldTypeNumber, Value: 1.0},
+				{Type: DocumentFieldTypeBool, Value: false},
+				{Type: DocumentFieldTypeNull},
+			}},
+		{"nil array", DocumentField{Type: DocumentFieldTypeArray, Value: []string(nil)}, nil},
+		{"map", DocumentField{Type: DocumentFieldTypeObject, Value: map[string]any{"n": 2, "tags": []string{"x"}}},
+			map[string]DocumentField{
+				"n":    {Type: DocumentFieldTypeNumber, Value: int64(2)},
+				"tags": {Type: DocumentFieldTypeArray, Value: []DocumentField{{Type: DocumentFieldTypeString, Value: "x"}}},
+			}},
+		{"struct", DocumentField{Type: DocumentFieldTypeObject, Value: address{City: "Kyiv", Zip: 1001}},
+			map[string]DocumentField{
+				"city": {Type: DocumentFieldTypeString, Value: "Kyiv"},
+				"Zip":  {Type: DocumentFieldTypeNumber, Value: int64(1001)},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.field)
+			require.NoError(t, err)
+
+			var decoded DocumentField
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, tt.field.Type, decoded.Type)
+			assert.Equal(t, tt.want, decoded.Value)
+
+			again, err := json.Marshal(decoded)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(data), string(again))
+		})
+	}
+}
+
+func TestDocumentField_JSONMismatch(t *testing.T) {
+	t.Run("encode", func(t *testing.T) {
+		for _, f := range []DocumentField{
+			{Type: DocumentFieldTypeString, Value: 1},
+			{Type: DocumentFieldTypeNumber, Value: "1"},
+			{Type: DocumentFieldTypeBool, Value: nil},
+			{Type: DocumentFieldTypeArray, Value: map[string]any{}},
+		} {
+			_, err := json.Marshal(f)
+			assert.ErrorIs(t, err, ErrDocumentFieldTypeMismatch, "%+v", f)
+		}
+	})
+
+	t.Run("encode unsupported", func(t *testing.T) {
+		for _, f := range []DocumentField{
+			{Type: DocumentFieldTypeNumber, Value: uint64(1 << 63)},
+			{Type: DocumentFieldTypeArray, Value: []any{make(chan int)}},
+			{Type: DocumentFieldTypeObject, Value: map[int]string{1: "a"}},
+		} {
+			_, err := json.Marshal(f)
+			assert.ErrorIs(t, err, ErrUnsupportedDocumentField, "%+v", f)
+		}
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		for _, data := range []string{
+			`{"Type":"string","Value":1}`,
+			`{"Type":"number","Value":"1"}`,
+			`{"Type":"bool","Value":null}`,
+			`{"Type":"array","Value":{}}`,
+			`{"Type":"object","Value":[]}`,
+		} {
+			var f DocumentField
+			assert.ErrorIs(t, json.Unmarshal([]byte(data), &f), ErrDocumentFieldTypeMismatch, data)
+		}
+		var f DocumentField
+		assert.ErrorIs(t, json.Unmarshal([]byte(`{"Type":"date","Value":"x"}`), &f), ErrUnsupportedDocumentField)
+	})
+}
+
+func TestDump_PreservesFieldTypes(t *testing.T) {
+	type profile struct {
+		ID     string   `json:"id"`
+		Age    int      `json:"age"`
+		Score  float64  `json:"score"`
+		Tags   []string `json:"tags"`
+		Active bool     `json:"active"`
+	}
+	s := NewStore()
+	coll, err := s.CreateCollection("profiles", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	in := profile{ID: "p1", Age: 30, Score: 4, Tags: []string{"a", "b"}, Active: true}
+	doc, err := MarshalDocument(in)
+	require.NoError(t, err)
+	require.NoError(t, coll.Put(*doc))
+
+	dump, err := s.Dump()
+	require.NoError(t, err)
+	restored, err := NewStoreFromDump(dump)
+	require.NoError(t, err)
+	coll, err = restored.GetCollection("profiles")
+	require.NoError(t, err)
+	got, err := coll.Get("p1")
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(30), got.Fields["age"].Value)
+	assert.Equal(t, 4.0, got.Fields["score"].Value)
+	assert.Equal(t, []DocumentField{
+		{Type: DocumentFieldTypeString, Value: "a"},
+		{Type: DocumentFieldTypeString, Value: "b"},
+	}, got.Fields["tags"].Value)
+
+	var out profile
+	require.NoError(t, UnmarshalDocument(got, &out))
+	assert.Equal(t, in, out)
+
+	second, err := restored.Dump()
+	require.NoError(t, err)
+	assert.Equal(t, string(dump), string(second))
+}
+
+func TestDump_ReservedObjectKeys(t *testing.T) {
+	s := NewStore()
+	coll, err := s.CreateCollection("docs", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	created := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	require.NoError(t, coll.Put(Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "d1"},
+		"meta": {Type: DocumentFieldTypeObject, Value: map[string]any{"created": created}},
+	}}))
+
+	for name, value := range map[string]any{
+		"object":          map[string]any{"$timestamp": "not a time"},
+		"valid looking":   map[string]any{"$decimal": "1.5"},
+		"nested":          map[string]any{"a": map[string]any{"$binary": "AA=="}},
+		"in array":        []any{map[string]any{"$other": 1}},
+		"document fields": map[string]DocumentField{"$timestamp": {Type: DocumentFieldTypeString, Value: "x"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			f, ok := fieldFromValue(value)
+			require.True(t, ok)
+			err := coll.Put(Document{Fields: map[string]DocumentField{
+				"id":   {Type: DocumentFieldTypeString, Value: "d2"},
+				"meta": f,
+			}})
+			assert.ErrorIs(t, err, ErrUnsupportedDocumentField)
+		})
+	}
+
+	dump, err := s.Dump()
+	require.NoError(t, err)
+	restored, err := NewStoreFromDump(dump)
+	require.NoError(t, err)
+	coll, err = restored.GetCollection("docs")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d1"}, ids(coll.List()))
+	got, err := coll.Get("d1")
+	require.NoError(t, err)
+	meta := got.Fields["meta"].Value.(map[string]DocumentField)
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeTimestamp, Value: created}, meta["created"])
+}
@@ -3,8 +3,11 @@ package documentstore
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -19,11 +22,58 @@ var (
 type Collection struct {
 	cfg       CollectionConfig
 	documents map[string]*Document
-	mu        sync.RWMutex
+	indexes   map[string]index
+	uniques   []*uniqueIndex
+	schema    *schemaChecker
+	// revision is the last revision assigned to a document in this collection.
+	revision uint64
+	// keySequence is the last key generated by KeyStrategySequence.
+	keySequence uint64
+	// name and wal are set when the collection belongs to a Store with a write-ahead log.
+	name string
+	wal  *writeAheadLog
+	// store is the Store the collection belongs to, used by $lookup.
+	store *Store
+	// walBatch collects records of a committing transaction instead of the wal.
+	walBatch *[]walRecord
+	// feed delivers changes to watchers, it is shared by the collections of a Store.
+	feed *changeFeed
+	// eventBatch collects changes of a committing transaction until it succeeds.
+	eventBatch *[]ChangeEvent
+	// undoBatch collects evictions of a committing transaction to undo them
+	// on rollback.
+	undoBatch *[]txUndo
+	// capped is set when the collection has MaxDocuments or MaxBytes.
+	capped *capState
+	// dropped is set by Store.DeleteCollection, writes through a handle of
+	// a deleted collection fail with ErrCollectionNotFound.
+	dropped bool
+	mu      sync.RWMutex
 }
 
 type CollectionConfig struct {
 	PrimaryKey string
+	// Secondary indexes, maintained on Put/Delete and used by Find.
+	Indexes []IndexConfig
+	// Unique (optionally compound) constraints enforced by Put.
+	Unique []UniqueConstraint
+	// Schema enforced by Put, nil accepts any fields. It must not be modified
+	// after the collection is created. Dumps store it next to the config.
+	Schema *Schema `json:"-"`
+	// KeyStrategy generates the primary key of documents passed to Insert
+	// without one.
+	KeyStrategy KeyStrategy `json:",omitempty"`
+	// ExpireField names a timestamp field, documents expire ExpireAfter
+	// after its time. See the expiry section of expiry.go.
+	ExpireField string        `json:",omitempty"`
+	ExpireAfter time.Duration `json:",omitempty"`
+	// MaxDocuments and MaxBytes cap the collection, 0 means no limit. Bytes
+	// are counted as the JSON encoding of the document fields. CapPolicy
+	// chooses between evicting the oldest documents (the default) and
+	// rejecting the write.
+	MaxDocuments int       `json:",omitempty"`
+	MaxBytes     int64     `json:",omitempty"`
+	CapPolicy    CapPolicy `json:",omitempty"`
 }
 
 func NewCollection(cfg *CollectionConfig) *Collection {
@@ -32,45 +82,111 @@ func NewCollection(cfg *CollectionConfig) *Collection {
 	}
 	if cfg != nil && strings.TrimSpace(cfg.PrimaryKey) != "" {
 		defaultCfg = *cfg
+		defaultCfg.Indexes = append([]IndexConfig(nil), cfg.Indexes...)
+		defaultCfg.Unique = append([]UniqueConstraint(nil), cfg.Unique...)
+	}
+	indexes := make(map[string]index, len(defaultCfg.Indexes))
+	for _, ic := range defaultCfg.Indexes {
+		if err := validateIndexes([]IndexConfig{ic}); err != nil {
+			pkgLogger.Error("[Collection] skipping index", slog.Any("error", err))
+			continue
+		}
+		indexes[ic.Field] = newIndex(ic)
+	}
+	uniques := make([]*uniqueIndex, 0, len(defaultCfg.Unique))
+	for _, uc := range defaultCfg.Unique {
+		if err := validateUniqueConstraints([]UniqueConstraint{uc}); err != nil {
+			pkgLogger.Error("[Collection] skipping unique constraint", slog.Any("error", err))
+			continue
+		}
+		uniques = append(uniques, newUniqueIndex(uc))
+	}
+	if err := validateExpiry(&defaultCfg); err != nil {
+		pkgLogger.Error("[Collection] skipping expiry", slog.Any("error", err))
+		defaultCfg.ExpireField, defaultCfg.ExpireAfter = "", 0
+	}
+	if err := validateKeyStrategy(defaultCfg.KeyStrategy); err != nil {
+		pkgLogger.Error("[Collection] skipping key strategy", slog.Any("error", err))
+		defaultCfg.KeyStrategy = KeyStrategyNone
+	}
+	if err := validateCap(&defaultCfg); err != nil {
+		pkgLogger.Error("[Collection] skipping limits", slog.Any("error", err))
+		defaultCfg.MaxDocuments, defaultCfg.MaxBytes, defaultCfg.CapPolicy = 0, 0, ""
+	}
+	var capped *capState
+	if defaultCfg.MaxDocuments > 0 || defaultCfg.MaxBytes > 0 {
+		capped = newCapState()
+	}
+	schema, err := compileSchema(defaultCfg.Schema)
+	if err != nil {
+		pkgLogger.Error("[Collection] skipping schema", slog.Any("error", err))
+		defaultCfg.Schema = nil
+	}
+	if schema != nil {
+		schema.primaryKey = defaultCfg.PrimaryKey
 	}
 	pkgLogger.Info("New collection is created")
 	return &Collection{
 		cfg:       defaultCfg,
 		documents: make(map[string]*Document),
+		indexes:   indexes,
+		uniques:   uniques,
+		schema:    schema,
+		feed:      newChangeFeed(),
+		capped:    capped,
 	}
 }
 
+// Put stores the document, replacing the one with the same primary key.
+// The stored document gets a new revision, the Revision of doc is ignored.
 func (s *Collection) Put(doc Document) error {
+	keyValue, err := s.documentKey(doc)
+	if err != nil {
+		return err
+	}
+	pk := s.cfg.PrimaryKey
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped {
+		pkgLogger.Error("[Collection Put] Error: collection was deleted", slog.String(pk, keyValue))
+		return ErrCollectionNotFound
+	}
+	if err := s.putLocked(keyValue, &doc); err != nil {
+		pkgLogger.Error("[Collection Put] Error: document rejected", slog.String(pk, keyValue), slog.Any("error", err))
+		return err
+	}
+	pkgLogger.Info(fmt.Sprintf("[Collection] Document with %s='%s' added", pk, keyValue))
+
+	return nil
+}
+
+// documentKey validates the primary key field of the document and returns its value.
+func (s *Collection) documentKey(doc Document) (string, error) {
 	// Потрібно перевірити що документ містить поле `{cfg.PrimaryKey}` типу `string`
 	if doc.Fields == nil {
 		pkgLogger.Error("[Collection Put] Error: Document is empty")
-		return ErrEmptyDocument
+		return "", ErrEmptyDocument
 	}
 	pk := s.cfg.PrimaryKey
 	fieldKey, exist := doc.Fields[pk]
 	if !exist {
 		pkgLogger.Error("primary key field is missing", "field", pk)
-		return ErrKeyMissing
+		return "", ErrKeyMissing
 	}
 	if fieldKey.Type != DocumentFieldTypeString {
 		pkgLogger.Error("[Collection Put] Error: Field  must be of type 'string", "field", pk)
-		return ErrValueTypeInvalid
+		return "", ErrValueTypeInvalid
 	}
 	keyValue, ok := fieldKey.Value.(string)
 	if !ok || strings.TrimSpace(keyValue) == "" {
 		pkgLogger.Error("[Collection] Error: value is not a non-empty string", "value", pk)
-		return ErrKeyEmpty
+		return "", ErrKeyEmpty
 	}
 	if strings.TrimSpace(keyValue) == "" {
 		pkgLogger.Error("[Collection] Error: value is empty", "value", pk)
-		return ErrValueEmpty
+		return "", ErrValueEmpty
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.documents[keyValue] = &doc
-	pkgLogger.Info(fmt.Sprintf("[Collection] Document with %s='%s' added", pk, keyValue))
-
-	return nil
+	return keyValue, nil
 }
 
 func (s *Collection) Get(key string) (*Document, error) {
@@ -80,8 +196,13 @@ func (s *Collection) Get(key string) (*Document, error) {
 		return nil, ErrKeyEmpty
 	}
 	s.mu.RLock()
-	defer s.mu.RUnlock()
 	doc, ok := s.documents[key]
+	expired := ok && s.expired(doc, time.Now())
+	s.mu.RUnlock()
+	if expired {
+		s.expireKey(key)
+		ok = false
+	}
 	if !ok {
 		fmt.Printf("[Collection Get] Document with key '%s' not found\n", key)
 		pkgLogger.Error(fmt.Sprintf("[Collection Get] Document with key %s  not found", key))
@@ -98,6 +219,10 @@ func (s *Collection) Delete(key string) error {
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if s.dropped {
+		pkgLogger.Error("[Collection Delete] Error: collection was deleted", slog.String("key", key))
+		return ErrCollectionNotFound
+	}
 	_, ok := s.documents[key]
 
 	if !ok {
@@ -105,18 +230,156 @@ func (s *Collection) Delete(key string) error {
 		return ErrDocumentNotFound
 	}
 
-	delete(s.documents, key)
+	if err := s.deleteLocked(key); err != nil {
+		pkgLogger.Error(fmt.Sprintf("[Collection Delete] Document with key '%s' not deleted", key), slog.Any("error", err))
+		return err
+	}
 	pkgLogger.Error(fmt.Sprintf("[Collection Delete] Document with key '%s' deleted successfully", key))
 
 	return nil
 }
 
+// List returns all documents ordered by primary key.
 func (s *Collection) List() []Document {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.documents))
+	for key := range s.documents {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 	docs := make([]Document, 0, len(s.documents))
-	for _, doc := range s.documents {
-		docs = append(docs, *doc)
+	for _, key := range keys {
+		docs = append(docs, *s.documents[key])
 	}
 	return docs
 }
+
+// putLocked stores the document under the next collection revision.
+// The write is logged before it is applied. Must be called with the write lock held.
+func (s *Collection) putLocked(key string, doc *Document) error {
+	return s.writeLocked(key, doc, ChangeReplace)
+}
+
+// writeLocked is putLocked that reports the write to watchers as change,
+// ChangeReplace becomes ChangeInsert for new keys and an empty change is not reported.
+func (s *Collection) writeLocked(key string, doc *Document, change ChangeType) error {
+	doc.Revision = s.revision + 1
+	if err := validateFields(doc); err != nil {
+		return err
+	}
+	if s.schema != nil {
+		if err := s.schema.check(doc.Fields); err != nil {
+			return err
+		}
+	}
+	if err := s.checkLocked(key, doc); err != nil {
+		return err
+	}
+	victims, err := s.capLocked(key, doc)
+	if err != nil {
+		return err
+	}
+	recs := make([]walRecord, 0, len(victims)+1)
+	for _, victim := range victims {
+		recs = append(recs, walRecord{Op: walOpDelete, Key: victim})
+	}
+	rec := walRecord{Op: walOpPut, Document: doc}
+	if s.cfg.KeyStrategy == KeyStrategySequence {
+		rec.KeySequence = s.keySequence
+	}
+	if err := s.logLocked(append(recs, rec)...); err != nil {
+		return err
+	}
+	s.evictLocked(victims)
+	prev, existed := s.documents[key]
+	s.applyLocked(key, doc)
+	s.revision = doc.Revision
+	if change == "" {
+		return nil
+	}
+	if !existed {
+		change, prev = ChangeInsert, nil
+	}
+	s.notifyLocked(ChangeEvent{Type: change, Key: key, Before: prev, After: doc})
+	return nil
+}
+
+// storeLocked stores the document as is, without logging it.
+// Used when restoring state. Must be called with the write lock held.
+func (s *Collection) storeLocked(key string, doc *Document) error {
+	if err := s.checkLocked(key, doc); err != nil {
+		return err
+	}
+	s.applyLocked(key, doc)
+	return nil
+}
+
+// deleteLocked logs and removes the document.
+// Must be called with the write lock held.
+func (s *Collection) deleteLocked(key string) error {
+	return s.discardLocked(key, ChangeDelete)
+}
+
+// discardLocked is deleteLocked that reports the removal to watchers as change.
+func (s *Collection) discardLocked(key string, change ChangeType) error {
+	prev, ok := s.documents[key]
+	if !ok {
+		return nil
+	}
+	if err := s.logLocked(walRecord{Op: walOpDelete, Key: key}); err != nil {
+		return err
+	}
+	s.removeLocked(key)
+	s.notifyLocked(ChangeEvent{Type: change, Key: key, Before: prev})
+	return nil
+}
+
+// checkLocked verifies unique constraints for the document.
+func (s *Collection) checkLocked(key string, doc *Document) error {
+	for _, u := range s.uniques {
+		if err := u.check(key, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyLocked replaces the document and keeps the indexes in sync.
+func (s *Collection) applyLocked(key string, doc *Document) {
+	if old, ok := s.documents[key]; ok {
+		s.unindexLocked(key, old)
+	}
+	s.documents[key] = doc
+	for _, ix := range s.indexes {
+		ix.add(key, doc)
+	}
+	for _, u := range s.uniques {
+		u.add(key, doc)
+	}
+	if s.capped != nil {
+		s.capped.track(key, doc)
+	}
+}
+
+// removeLocked removes the document and its index entries.
+func (s *Collection) removeLocked(key string) {
+	old, ok := s.documents[key]
+	if !ok {
+		return
+	}
+	s.unindexLocked(key, old)
+	delete(s.documents, key)
+	if s.capped != nil {
+		s.capped.untrack(key)
+	}
+}
+
+func (s *Collection) unindexLocked(key string, old *Document) {
+	for _, ix := range s.indexes {
+		ix.remove(key, old)
+	}
+	for _, u := range s.uniques {
+		u.remove(key, old)
+	}
+}
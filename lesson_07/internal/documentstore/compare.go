@@ -0,0 +1,224 @@
+package documentstore
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Helpers for comparing DocumentField values. They are shared by queries,
+// indexes and sorting so that all of them agree on ordering and equality.
+
+// fieldFromValue turns a raw Go value into a DocumentField, inferring its type.
+// A DocumentField passed in is returned as is, nil becomes a null field and
+// values with custom marshalers are converted the same way MarshalDocument
+// converts them.
+func fieldFromValue(v any) (DocumentField, bool) {
+	if f, ok := v.(DocumentField); ok {
+		return f, true
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Kind() == reflect.Pointer {
+		return DocumentField{Type: DocumentFieldTypeNull}, true
+	}
+	if f, ok, err := marshalCustom(rv); ok {
+		return f, err == nil
+	}
+	t, ok := kindFieldType(rv.Kind())
+	if !ok {
+		return DocumentField{}, false
+	}
+	return DocumentField{Type: t, Value: rv.Interface()}, true
+}
+
+// compareFields returns -1, 0 or 1 when both fields are of the same ordered type.
+// Numbers and decimals compare with each other exactly.
+// ok is false for fields of different types or for types without ordering.
+func compareFields(a, b DocumentField) (int, bool) {
+	if orderType(a.Type) != orderType(b.Type) {
+		return 0, false
+	}
+	if a.Type == DocumentFieldTypeDecimal || b.Type == DocumentFieldTypeDecimal {
+		ar, aok := toRat(a.Value)
+		br, bok := toRat(b.Value)
+		if !aok || !bok {
+			return 0, false
+		}
+		return ar.Cmp(br), true
+	}
+	switch a.Type {
+	case DocumentFieldTypeNumber:
+		return compareNumbers(a.Value, b.Value)
+	case DocumentFieldTypeTimestamp:
+		at, aok := a.Value.(time.Time)
+		bt, bok := b.Value.(time.Time)
+		if !aok || !bok {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	case DocumentFieldTypeBinary:
+		ab, aok := a.Value.([]byte)
+		bb, bok := b.Value.([]byte)
+		if !aok || !bok {
+			return 0, false
+		}
+		return bytes.Compare(ab, bb), true
+	case DocumentFieldTypeNull:
+		return 0, true
+	case DocumentFieldTypeString:
+		as, aok := a.Value.(string)
+		bs, bok := b.Value.(string)
+		if !aok || !bok {
+			return 0, false
+		}
+		return strings.Compare(as, bs), true
+	case DocumentFieldTypeBool:
+		ab, aok := a.Value.(bool)
+		bb, bok := b.Value.(bool)
+		if !aok || !bok {
+			return 0, false
+		}
+		switch {
+		case ab == bb:
+			return 0, true
+		case !ab:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// equalFields reports whether two fields have the same type and value.
+// A decimal equals a number of the same value.
+func equalFields(a, b DocumentField) bool {
+	if a.Type != b.Type {
+		c, ok := compareFields(a, b)
+		return ok && c == 0
+	}
+	return equalValues(a.Value, b.Value)
+}
+
+// equalValues compares raw values, treating all numeric kinds as numbers and
+// all slices/maps element by element. Elements of DocumentField trees are
+// compared by value.
+func equalValues(a, b any) bool {
+	if f, ok := a.(DocumentField); ok {
+		a = f.Value
+	}
+	if f, ok := b.(DocumentField); ok {
+		b = f.Value
+	}
+	if c, ok := compareNumbers(a, b); ok {
+		return c == 0
+	}
+	switch av := a.(type) {
+	case time.Time:
+		bt, ok := b.(time.Time)
+		return ok && av.Equal(bt)
+	case *big.Rat:
+		br, ok := toRat(b)
+		return ok && av.Cmp(br) == 0
+	}
+	if _, ok := b.(*big.Rat); ok {
+		ar, ok := toRat(a)
+		return ok && ar.Cmp(b.(*big.Rat)) == 0
+	}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !av.IsValid() || !bv.IsValid() {
+		return !av.IsValid() && !bv.IsValid()
+	}
+	switch av.Kind() {
+	case reflect.Slice, reflect.Array:
+		if bv.Kind() != reflect.Slice && bv.Kind() != reflect.Array {
+			return false
+		}
+		if av.Len() != bv.Len() {
+			return false
+		}
+		for i := 0; i < av.Len(); i++ {
+			if !equalValues(av.Index(i).Interface(), bv.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if bv.Kind() != reflect.Map || av.Len() != bv.Len() {
+			return false
+		}
+		for _, k := range av.MapKeys() {
+			other := bv.MapIndex(k)
+			if !other.IsValid() || !equalValues(av.MapIndex(k).Interface(), other.Interface()) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// compareNumbers compares integer values exactly and falls back to float64
+// when at least one of them is a floating point number.
+func compareNumbers(a, b any) (int, bool) {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !isNumberKind(av) || !isNumberKind(bv) {
+		return 0, false
+	}
+	if isIntKind(av.Kind()) && isIntKind(bv.Kind()) {
+		return cmpOrdered(av.Int(), bv.Int()), true
+	}
+	if isUintKind(av.Kind()) && isUintKind(bv.Kind()) {
+		return cmpOrdered(av.Uint(), bv.Uint()), true
+	}
+	return cmpOrdered(toFloat(av), toFloat(bv)), true
+}
+
+func cmpOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isNumberKind(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	k := v.Kind()
+	return isIntKind(k) || isUintKind(k) || k == reflect.Float32 || k == reflect.Float64
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUintKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case isIntKind(v.Kind()):
+		return float64(v.Int())
+	case isUintKind(v.Kind()):
+		return float64(v.Uint())
+	}
+	return v.Float()
+}
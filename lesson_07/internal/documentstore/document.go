@@ -3,8 +3,9 @@ package documentstore
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
-	"strings"
+	"strconv"
 )
 
 var (
@@ -24,6 +25,14 @@ const (
 	DocumentFieldTypeBool   DocumentFieldType = "bool"
 	DocumentFieldTypeArray  DocumentFieldType = "array"
 	DocumentFieldTypeObject DocumentFieldType = "object"
+	// DocumentFieldTypeTimestamp holds a time.Time.
+	DocumentFieldTypeTimestamp DocumentFieldType = "timestamp"
+	// DocumentFieldTypeBinary holds a []byte.
+	DocumentFieldTypeBinary DocumentFieldType = "binary"
+	// DocumentFieldTypeNull has no value.
+	DocumentFieldTypeNull DocumentFieldType = "null"
+	// DocumentFieldTypeDecimal holds an exact decimal number as *big.Rat.
+	DocumentFieldTypeDecimal DocumentFieldType = "decimal"
 )
 
 type DocumentField struct {
@@ -32,14 +41,50 @@ type DocumentField struct {
 }
 type Document struct {
 	Fields map[string]DocumentField
+	// Revision is assigned by the collection on every write and grows monotonically.
+	Revision uint64
 }
 
+// kindFieldType maps a reflect.Kind to the DocumentFieldType used to store it.
+func kindFieldType(kind reflect.Kind) (DocumentFieldType, bool) {
+	switch kind {
+	case reflect.String:
+		return DocumentFieldTypeString, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return DocumentFieldTypeNumber, true
+	case reflect.Bool:
+		return DocumentFieldTypeBool, true
+	case reflect.Slice, reflect.Array:
+		return DocumentFieldTypeArray, true
+	case reflect.Map, reflect.Struct:
+		return DocumentFieldTypeObject, true
+	}
+	return "", false
+}
+
+var documentFieldType = reflect.TypeOf(DocumentField{})
+
+// MarshalDocument converts a struct into a document. Nested structs and maps
+// become objects of fields (map[string]DocumentField), slices and arrays become
+// arrays of typed elements ([]DocumentField). Pointers are followed, nil
+// pointers become null fields. time.Time, big.Rat, []byte and types
+// implementing DocumentMarshaler, FieldMarshaler or encoding.TextMarshaler are
+// converted by marshalCustom.
 func MarshalDocument(input any) (*Document, error) {
 	if input == nil {
 		return nil, ErrDocumentInputNull
 	}
 
 	v := reflect.ValueOf(input)
+	// a nil pointer is no struct, and a value receiver cannot be called on it
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, ErrDocumentInputIsNotStruct
+	}
+	if m, ok := implementer(v, documentMarshalerType); ok {
+		return m.(DocumentMarshaler).MarshalDocument()
+	}
 	// Check for pointer and get the element
 	if v.Kind() == reflect.Pointer {
 		v = v.Elem()
@@ -49,65 +94,123 @@ func MarshalDocument(input any) (*Document, error) {
 		return nil, ErrDocumentInputIsNotStruct
 	}
 
-	doc := &Document{
-		Fields: make(map[string]DocumentField),
+	fields, err := marshalStruct(v)
+	if err != nil {
+		return nil, err
 	}
+	return &Document{Fields: fields}, nil
+}
 
-	// get type info
-	typ := v.Type()
-	// handle all struct fields
-	for i := 0; i < v.NumField(); i++ {
-		// get field type info
-		fieldType := typ.Field(i)
-
-		if !fieldType.IsExported() {
+// marshalStruct converts struct fields according to their doc and json tags.
+func marshalStruct(v reflect.Value) (map[string]DocumentField, error) {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		pkgLogger.Error("invalid struct tags", slog.String("type", v.Type().String()), slog.Any("error", err))
+		return nil, err
+	}
+	doc := make(map[string]DocumentField, len(fields))
+	for _, f := range fields {
+		value, ok := fieldByIndex(v, f.index, false)
+		if !ok {
+			continue // nil embedded pointer
+		}
+		if f.omitEmpty && isEmptyValue(value) {
 			continue
 		}
 
-		name := fieldType.Name
-		value := v.Field(i)
+		field, err := marshalField(value)
+		if err != nil {
+			pkgLogger.Error(fmt.Sprintf("Skipping field '%s': unsupported type '%s'", f.name, f.typ))
+			return nil, err
+		}
+		if f.asString {
+			field = quoteField(field)
+		}
+		doc[f.name] = field
+	}
+	return doc, nil
+}
 
-		// Check for json tag
-		if tag := fieldType.Tag.Get("json"); tag != "" {
+// quoteField stores a number or bool as a string, for fields tagged with the string option.
+func quoteField(f DocumentField) DocumentField {
+	switch f.Type {
+	case DocumentFieldTypeNumber:
+		v := reflect.ValueOf(f.Value)
+		switch {
+		case isIntKind(v.Kind()):
+			return DocumentField{Type: DocumentFieldTypeString, Value: strconv.FormatInt(v.Int(), 10)}
+		case isUintKind(v.Kind()):
+			return DocumentField{Type: DocumentFieldTypeString, Value: strconv.FormatUint(v.Uint(), 10)}
+		}
+		return DocumentField{Type: DocumentFieldTypeString, Value: strconv.FormatFloat(v.Float(), 'g', -1, 64)}
+	case DocumentFieldTypeBool:
+		return DocumentField{Type: DocumentFieldTypeString, Value: strconv.FormatBool(f.Value.(bool))}
+	}
+	return f
+}
 
-			name = strings.Split(tag, ",")[0]
-			if name == "-" {
-				continue
+// marshalField converts a Go value into a DocumentField tree.
+func marshalField(v reflect.Value) (DocumentField, error) {
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return DocumentField{Type: DocumentFieldTypeNull}, nil
+		}
+		return marshalField(v.Elem())
+	}
+	if field, ok, err := marshalCustom(v); ok {
+		return field, err
+	}
+	// Determine DocumentFieldType
+	docType, ok := kindFieldType(v.Kind())
+	if !ok {
+		return DocumentField{}, ErrUnsupportedDocumentField
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return DocumentField{Type: docType}, nil
+		}
+		elems := make([]DocumentField, v.Len())
+		for i := range elems {
+			elem, err := marshalField(v.Index(i))
+			if err != nil {
+				return DocumentField{}, err
 			}
+			elems[i] = elem
 		}
-
-		// Determine DocumentFieldType
-		var docType DocumentFieldType
-		switch value.Kind() {
-		case reflect.String:
-			docType = DocumentFieldTypeString
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-			reflect.Float32, reflect.Float64:
-			docType = DocumentFieldTypeNumber
-		case reflect.Bool:
-			docType = DocumentFieldTypeBool
-		case reflect.Slice, reflect.Array:
-			docType = DocumentFieldTypeArray
-		case reflect.Map, reflect.Struct:
-			docType = DocumentFieldTypeObject
-		default:
-			pkgLogger.Error(fmt.Sprintf("Skipping field '%s': unsupported type '%s'", name, value.Kind()))
-			return nil, ErrUnsupportedDocumentField
-			//continue
+		return DocumentField{Type: docType, Value: elems}, nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return DocumentField{}, ErrUnsupportedDocumentField
 		}
-
-		// map[string]DocumentField
-		doc.Fields[name] = DocumentField{
-			Type:  docType,
-			Value: value.Interface(), // Return value as any
+		if v.IsNil() {
+			return DocumentField{Type: docType}, nil
 		}
+		fields := make(map[string]DocumentField, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elem, err := marshalField(iter.Value())
+			if err != nil {
+				return DocumentField{}, err
+			}
+			fields[iter.Key().String()] = elem
+		}
+		return DocumentField{Type: docType, Value: fields}, nil
+	case reflect.Struct:
+		fields, err := marshalStruct(v)
+		if err != nil {
+			return DocumentField{}, err
+		}
+		return DocumentField{Type: docType, Value: fields}, nil
 	}
-
-	return doc, nil
+	return DocumentField{Type: docType, Value: v.Interface()}, nil
 }
 
-func UnmarshalDocument(doc *Document, output any) error {
+// UnmarshalDocument fills the struct pointed to by output from doc.
+// By default it is lenient: fields that do not fit are skipped with a warning
+// in the log. Options enable strict checks, violations are returned together
+// as *UnmarshalError.
+func UnmarshalDocument(doc *Document, output any, opts ...UnmarshalOption) error {
 
 	if doc == nil || doc.Fields == nil {
 		return ErrUnmarshalDocumentIsNull
@@ -119,6 +222,9 @@ func UnmarshalDocument(doc *Document, output any) error {
 	if val.Kind() != reflect.Pointer || val.IsNil() {
 		return ErrUnmarshalOutputIsNull
 	}
+	if u, ok := output.(DocumentUnmarshaler); ok {
+		return u.UnmarshalDocument(doc)
+	}
 
 	// Get element
 	val = val.Elem()
@@ -128,36 +234,13 @@ func UnmarshalDocument(doc *Document, output any) error {
 		return ErrUnmarshalOutputIsNotStruct
 	}
 
-	// Get type info
-	typ := val.Type()
-
-	for i := 0; i < val.NumField(); i++ {
-		fieldVal := val.Field(i)
-		fieldType := typ.Field(i)
-
-		if !fieldVal.CanSet() {
-			continue // private field
-		}
-
-		// Get field name in a document by json tag or field name
-		name := fieldType.Name
-		if tag := fieldType.Tag.Get("json"); tag != "" {
-			name = strings.Split(tag, ",")[0]
-		}
-		// Doc has field
-		if docField, ok := doc.Fields[name]; ok {
-			// Get doc field value
-			storedVal := reflect.ValueOf(docField.Value)
-
-			// cast to appropriate type
-			if storedVal.Type().ConvertibleTo(fieldVal.Type()) {
-				fieldVal.Set(storedVal.Convert(fieldVal.Type()))
-			} else {
-				// Skip incompatible types
-				fmt.Printf("Warning: type mismatch for field %s\n", name)
-			}
-		}
+	if _, err := structFields(val.Type()); err != nil {
+		return err
 	}
-
-	return nil
+	d := &decoder{}
+	for _, opt := range opts {
+		opt(&d.opts)
+	}
+	d.decodeStruct("", reflect.ValueOf(doc.Fields), val)
+	return d.err()
 }
@@ -2,6 +2,7 @@
 package documentstore
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -78,6 +79,12 @@ func TestMarshalDocument_Errors(t *testing.T) {
 	if _, err := MarshalDocument(123); err != ErrDocumentInputIsNotStruct {
 		t.Fatalf("expected ErrDocumentInputIsNotStruct, got %v", err)
 	}
+	// nil pointers, also of types with a MarshalDocument method
+	for _, input := range []any{(*hasUnsupported)(nil), (*money)(nil)} {
+		if _, err := MarshalDocument(input); err != ErrDocumentInputIsNotStruct {
+			t.Fatalf("expected ErrDocumentInputIsNotStruct for %T, got %v", input, err)
+		}
+	}
 	// unsupported field type triggers error
 	if _, err := MarshalDocument(hasUnsupported{}); err != ErrUnsupportedDocumentField {
 		t.Fatalf("expected ErrUnsupportedDocumentField, got %v", err)
@@ -154,3 +161,156 @@ func TestUnmarshalDocument_Errors(t *testing.T) {
 		t.Fatalf("expected ErrUnmarshalOutputIsNotStruct, got %v", err)
 	}
 }
+
+type address struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type employee struct {
+	ID       string            `json:"id"`
+	Home     address           `json:"home"`
+	Previous []address         `json:"previous"`
+	Manager  *employee         `json:"manager"`
+	Scores   map[string]int    `json:"scores"`
+	Matrix   [][]int           `json:"matrix"`
+	Pair     [2]string         `json:"pair"`
+	Extra    any               `json:"extra"`
+	Labels   map[string]string `json:"labels"`
+}
+
+func TestMarshalDocument_Nested(t *testing.T) {
+	e := employee{
+		ID:       "e1",
+		Home:     address{City: "Kyiv", Zip: "01001"},
+		Previous: []address{{City: "Lviv"}},
+		Manager:  &employee{ID: "e0"},
+		Scores:   map[string]int{"go": 5},
+		Matrix:   [][]int{{1, 2}, {3}},
+		Pair:     [2]string{"a", "b"},
+		Extra:    map[string]any{"flag": true},
+	}
+	doc, err := MarshalDocument(e)
+	if err != nil {
+		t.Fatalf("MarshalDocument error: %v", err)
+	}
+
+	home, ok := doc.Fields["home"].Value.(map[string]DocumentField)
+	if !ok || doc.Fields["home"].Type != DocumentFieldTypeObject {
+		t.Fatalf("home should be an object of fields: %+v", doc.Fields["home"])
+	}
+	if home["city"] != (DocumentField{Type: DocumentFieldTypeString, Value: "Kyiv"}) {
+		t.Fatalf("unexpected home.city: %+v", home["city"])
+	}
+	previous, ok := doc.Fields["previous"].Value.([]DocumentField)
+	if !ok || len(previous) != 1 || previous[0].Type != DocumentFieldTypeObject {
+		t.Fatalf("previous should be an array of objects: %+v", doc.Fields["previous"])
+	}
+	manager, ok := doc.Fields["manager"].Value.(map[string]DocumentField)
+	if !ok || manager["id"].Value != "e0" {
+		t.Fatalf("manager pointer should be followed: %+v", doc.Fields["manager"])
+	}
+	if f := manager["manager"]; f.Type != DocumentFieldTypeNull || f.Value != nil {
+		t.Fatalf("nil pointer should be null: %+v", f)
+	}
+	if f := doc.Fields["labels"]; f.Type != DocumentFieldTypeObject || f.Value != nil {
+		t.Fatalf("nil map should be an empty object: %+v", f)
+	}
+
+	type badMap struct {
+		M map[int]string
+	}
+	if _, err := MarshalDocument(badMap{M: map[int]string{1: "a"}}); err != ErrUnsupportedDocumentField {
+		t.Fatalf("expected ErrUnsupportedDocumentField, got %v", err)
+	}
+}
+
+func TestUnmarshalDocument_Nested(t *testing.T) {
+	e := employee{
+		ID:       "e1",
+		Home:     address{City: "Kyiv", Zip: "01001"},
+		Previous: []address{{City: "Lviv"}, {City: "Odesa", Zip: "65000"}},
+		Manager:  &employee{ID: "e0", Scores: map[string]int{"lead": 1}},
+		Scores:   map[string]int{"go": 5},
+		Matrix:   [][]int{{1, 2}, {3}},
+		Pair:     [2]string{"a", "b"},
+		Extra:    map[string]any{"flag": true, "list": []any{"x"}},
+	}
+	doc, err := MarshalDocument(&e)
+	if err != nil {
+		t.Fatalf("MarshalDocument error: %v", err)
+	}
+
+	check := func(t *testing.T, doc *Document) {
+		t.Helper()
+		var out employee
+		if err := UnmarshalDocument(doc, &out); err != nil {
+			t.Fatalf("UnmarshalDocument error: %v", err)
+		}
+		if !reflect.DeepEqual(e, out) {
+			t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", out, e)
+		}
+	}
+	t.Run("document", func(t *testing.T) { check(t, doc) })
+
+	t.Run("dump", func(t *testing.T) {
+		s := NewStore()
+		coll, err := s.CreateCollection("employees", &CollectionConfig{PrimaryKey: "id"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := coll.Put(*doc); err != nil {
+			t.Fatal(err)
+		}
+		dump, err := s.Dump()
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored, err := NewStoreFromDump(dump)
+		if err != nil {
+			t.Fatal(err)
+		}
+		coll, _ = restored.GetCollection("employees")
+		loaded, err := coll.Get("e1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, loaded)
+	})
+}
+
+func TestNestedFields_Queries(t *testing.T) {
+	coll := NewCollection(&CollectionConfig{
+		PrimaryKey: "id",
+		Indexes:    []IndexConfig{{Field: "tags", Type: IndexTypeHash}},
+	})
+	type tagged struct {
+		ID   string   `json:"id"`
+		Tags []string `json:"tags"`
+	}
+	doc, err := MarshalDocument(tagged{ID: "t1", Tags: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := coll.Put(*doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// plain Go values match DocumentField trees
+	found, err := coll.Find(Eq("tags", []string{"a", "b"}))
+	if err != nil || len(found) != 1 {
+		t.Fatalf("expected one match, got %d (%v)", len(found), err)
+	}
+
+	updated, err := coll.Update("t1", Push("tags", "c"), Pull("tags", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out tagged
+	if err := UnmarshalDocument(updated, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out.Tags, []string{"b", "c"}) {
+		t.Fatalf("unexpected tags after update: %v", out.Tags)
+	}
+}
@@ -0,0 +1,169 @@
+package documentstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrDumpChecksum = errors.New("dump checksum mismatch")
+	ErrDumpOptions  = errors.New("invalid dump options")
+)
+
+const checksumPrefix = "sha256:"
+
+type DumpOptions struct {
+	// Keep is the number of previous dumps kept next to the file
+	// as filename.1 (newest) ... filename.N (oldest). 0 keeps none.
+	Keep int
+}
+
+// dumpChecksum hashes a dump without its checksum field. The dump is
+// re-encoded compactly, so the result does not depend on indentation.
+func dumpChecksum(dump []byte) (string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(dump, &raw); err != nil {
+		return "", fmt.Errorf("failed to compute dump checksum: %w", err)
+	}
+	delete(raw, "checksum")
+	canonical, err := json.Marshal(raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute dump checksum: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return checksumPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+// verifyDumpChecksum accepts dumps without checksum written by older versions.
+func verifyDumpChecksum(dump []byte, checksum string) error {
+	if checksum == "" {
+		pkgLogger.Warn("dump has no checksum, skipping verification")
+		return nil
+	}
+	actual, err := dumpChecksum(dump)
+	if err != nil {
+		return err
+	}
+	if actual != checksum {
+		return fmt.Errorf("%w: expected %s, got %s", ErrDumpChecksum, checksum, actual)
+	}
+	return nil
+}
+
+// writeDumpFile replaces filename atomically: the dump is written to a temporary
+// file in the same directory, synced and renamed over the target, so a crash
+// leaves either the old or the new dump. With keep > 0 previous dumps are rotated.
+func writeDumpFile(filename string, dump []byte, keep int) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		pkgLogger.Error("failed to create temporary dump file", slog.String("file", filename), slog.Any("error", err))
+		return err
+	}
+	tmpName := tmp.Name()
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+	}
+	if _, err := tmp.Write(dump); err != nil {
+		cleanup()
+		pkgLogger.Error("failed to write dump to file", slog.String("file", tmpName), slog.Any("error", err))
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		pkgLogger.Error("failed to sync dump file", slog.String("file", tmpName), slog.Any("error", err))
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		cleanup()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if keep > 0 {
+		if err := rotateDumps(filename, keep); err != nil {
+			_ = os.Remove(tmpName)
+			pkgLogger.Error("failed to rotate dumps", slog.String("file", filename), slog.Any("error", err))
+			return err
+		}
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		_ = os.Remove(tmpName)
+		pkgLogger.Error("failed to write dump to file", slog.String("file", filename), slog.Any("error", err))
+		return err
+	}
+	syncDir(dir)
+	pkgLogger.Info("dump written to file", slog.String("file", filename), slog.Int("bytes", len(dump)))
+	return nil
+}
+
+// rotateDumps shifts filename.i to filename.i+1 and copies the current dump to
+// filename.1. The current dump stays in place until it is replaced.
+func rotateDumps(filename string, keep int) error {
+	if _, err := os.Stat(filename); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := os.Remove(rotatedName(filename, keep)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := keep - 1; i >= 1; i-- {
+		err := os.Rename(rotatedName(filename, i), rotatedName(filename, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	first := rotatedName(filename, 1)
+	if err := os.Link(filename, first); err == nil {
+		return nil
+	}
+	return copyFile(filename, first)
+}
+
+func rotatedName(filename string, i int) string {
+	return fmt.Sprintf("%s.%d", filename, i)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncDir makes the rename durable. Not every platform supports it,
+// so failures are only logged.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		pkgLogger.Warn("failed to open dump directory for sync", slog.String("dir", dir), slog.Any("error", err))
+		return
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		pkgLogger.Warn("failed to sync dump directory", slog.String("dir", dir), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,85 @@
+package documentstore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dumpedStore(t *testing.T, emails ...string) *Store {
+	t.Helper()
+	s := NewStore()
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	for i, email := range emails {
+		require.NoError(t, users.Put(userDoc(string(rune('a'+i)), email, "t1")))
+	}
+	return s
+}
+
+func TestDump_Checksum(t *testing.T) {
+	dump, err := dumpedStore(t, "a@example.com").Dump()
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `"checksum": "sha256:`)
+
+	_, err = NewStoreFromDump(dump)
+	require.NoError(t, err)
+
+	t.Run("tampered dump is rejected", func(t *testing.T) {
+		tampered := bytes.Replace(dump, []byte("a@example.com"), []byte("x@example.com"), 1)
+		_, err := NewStoreFromDump(tampered)
+		assert.ErrorIs(t, err, ErrDumpChecksum)
+	})
+
+	t.Run("dump without checksum is accepted", func(t *testing.T) {
+		legacy := []byte(`{"collections":{"users":{"config":{"PrimaryKey":"id"},"documents":[]}}}`)
+		s, err := NewStoreFromDump(legacy)
+		require.NoError(t, err)
+		_, err = s.GetCollection("users")
+		assert.NoError(t, err)
+	})
+}
+
+func TestDumpToFileWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "dump.json")
+
+	for _, email := range []string{"1@example.com", "2@example.com", "3@example.com", "4@example.com"} {
+		require.NoError(t, dumpedStore(t, email).DumpToFileWithOptions(filename, DumpOptions{Keep: 2}))
+	}
+
+	emailIn := func(name string) string {
+		s, err := NewStoreFromFile(name)
+		require.NoError(t, err)
+		users, err := s.GetCollection("users")
+		require.NoError(t, err)
+		doc, err := users.Get("a")
+		require.NoError(t, err)
+		return doc.Fields["email"].Value.(string)
+	}
+	assert.Equal(t, "4@example.com", emailIn(filename))
+	assert.Equal(t, "3@example.com", emailIn(filename+".1"))
+	assert.Equal(t, "2@example.com", emailIn(filename+".2"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	assert.ElementsMatch(t, []string{"dump.json", "dump.json.1", "dump.json.2"}, names)
+
+	t.Run("negative keep", func(t *testing.T) {
+		err := NewStore().DumpToFileWithOptions(filename, DumpOptions{Keep: -1})
+		assert.ErrorIs(t, err, ErrDumpOptions)
+	})
+
+	t.Run("missing directory leaves no file", func(t *testing.T) {
+		err := NewStore().DumpToFile(filepath.Join(dir, "missing", "dump.json"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,132 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidExpiry = errors.New("[Collection] Error: invalid expiry config")
+)
+
+// defaultReapInterval is how often the Store removes expired documents.
+const defaultReapInterval = time.Second
+
+// Documents of a collection with CollectionConfig.ExpireField expire at the
+// timestamp in that field plus ExpireAfter. With ExpireAfter 0 the field
+// holds the expiry time itself, e.g. "expiresAt", otherwise it is a TTL
+// counted from a time such as "createdAt". Documents without a timestamp
+// in the field never expire.
+//
+// Expired documents are not returned by Get and are removed by a goroutine of
+// the Store, which runs until Store.Close. Removals are logged to the
+// write-ahead log and reported to watchers as ChangeExpire.
+
+func validateExpiry(cfg *CollectionConfig) error {
+	if cfg.ExpireAfter < 0 {
+		return fmt.Errorf("%w: negative ExpireAfter %s", ErrInvalidExpiry, cfg.ExpireAfter)
+	}
+	if cfg.ExpireAfter > 0 && strings.TrimSpace(cfg.ExpireField) == "" {
+		return fmt.Errorf("%w: ExpireAfter requires ExpireField", ErrInvalidExpiry)
+	}
+	if cfg.ExpireField != "" {
+		if _, err := parsePath(cfg.ExpireField); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidExpiry, err)
+		}
+	}
+	return nil
+}
+
+// expired reports whether doc has expired at now.
+func (s *Collection) expired(doc *Document, now time.Time) bool {
+	if s.cfg.ExpireField == "" {
+		return false
+	}
+	f, ok := doc.GetPath(s.cfg.ExpireField)
+	if !ok || f.Type != DocumentFieldTypeTimestamp {
+		return false
+	}
+	at, ok := f.Value.(time.Time)
+	return ok && !now.Before(at.Add(s.cfg.ExpireAfter))
+}
+
+// expireKey removes the document with key if it has expired, it is used by
+// Get which finds expired documents under the read lock.
+func (s *Collection) expireKey(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc, ok := s.documents[key]; ok && s.expired(doc, time.Now()) {
+		if err := s.discardLocked(key, ChangeExpire); err != nil {
+			pkgLogger.Error("[Collection] failed to remove expired document", slog.String("key", key), slog.Any("error", err))
+		}
+	}
+}
+
+// expireLocked removes the documents that have expired at now and returns
+// their number. Must be called with the write lock held.
+func (s *Collection) expireLocked(now time.Time) (int, error) {
+	removed := 0
+	for key, doc := range s.documents {
+		if !s.expired(doc, now) {
+			continue
+		}
+		if err := s.discardLocked(key, ChangeExpire); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// expire removes expired documents from all collections of the store.
+func (s *Store) expire(now time.Time) int {
+	s.mu.RLock()
+	collections := make(map[string]*Collection, len(s.collections))
+	for name, coll := range s.collections {
+		if coll.cfg.ExpireField != "" {
+			collections[name] = coll
+		}
+	}
+	s.mu.RUnlock()
+
+	total := 0
+	for name, coll := range collections {
+		coll.mu.Lock()
+		removed, err := coll.expireLocked(now)
+		coll.mu.Unlock()
+		if err != nil {
+			pkgLogger.Error("[Store] failed to remove expired documents", slog.String("collection", name), slog.Any("error", err))
+		}
+		if removed > 0 {
+			pkgLogger.Info("[Store] expired documents removed", slog.String("collection", name), slog.Int("documents", removed))
+		}
+		total += removed
+	}
+	return total
+}
+
+// startReaperLocked starts the goroutine removing expired documents unless it
+// is running or the store is closed. Must be called with s.mu held.
+func (s *Store) startReaperLocked() {
+	if s.reaperStop != nil || s.closed {
+		return
+	}
+	s.reaperStop = make(chan struct{})
+	s.reaperDone = make(chan struct{})
+	go func(interval time.Duration, stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				s.expire(now)
+			}
+		}
+	}(s.reapInterval, s.reaperStop, s.reaperDone)
+}
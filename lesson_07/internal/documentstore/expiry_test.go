@@ -0,0 +1,132 @@
+package documentstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sessionDoc(id string, at time.Time) Document {
+	return Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: id},
+		"at": {Type: DocumentFieldTypeTimestamp, Value: at},
+	}}
+}
+
+func TestCollection_ExpireOnGet(t *testing.T) {
+	now := time.Now()
+	s := NewStore()
+	defer s.Close()
+	sessions, err := s.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", ExpireField: "at"})
+	require.NoError(t, err)
+	ch, err := sessions.Watch(t.Context(), WatchOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, sessions.Put(sessionDoc("old", now.Add(-time.Minute))))
+	require.NoError(t, sessions.Put(sessionDoc("new", now.Add(time.Hour))))
+	require.NoError(t, sessions.Put(userFields("forever", nil)))
+
+	_, err = sessions.Get("old")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	assert.Equal(t, []string{"forever", "new"}, ids(sessions.List()), "expired document is removed")
+	_, err = sessions.Get("new")
+	assert.NoError(t, err)
+
+	events, _ := drain(ch)
+	require.Len(t, events, 4)
+	assert.Equal(t, ChangeExpire, events[3].Type)
+	assert.Equal(t, "old", events[3].Key)
+}
+
+func TestStore_Expire(t *testing.T) {
+	now := time.Now()
+	s := NewStore()
+	defer s.Close()
+	tokens, err := s.CreateCollection("tokens", &CollectionConfig{PrimaryKey: "id", ExpireField: "at", ExpireAfter: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, tokens.Put(sessionDoc("t1", now.Add(-2*time.Hour))))
+	require.NoError(t, tokens.Put(sessionDoc("t2", now.Add(-10*time.Minute))))
+
+	assert.Equal(t, 1, s.expire(now))
+	assert.Equal(t, []string{"t2"}, ids(tokens.List()))
+	assert.Equal(t, 1, s.expire(now.Add(time.Hour)))
+	assert.Empty(t, tokens.List())
+}
+
+func TestStore_Reaper(t *testing.T) {
+	s := NewStore()
+	s.reapInterval = 5 * time.Millisecond
+	sessions, err := s.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", ExpireField: "at"})
+	require.NoError(t, err)
+	require.NoError(t, sessions.Put(sessionDoc("s1", time.Now().Add(20*time.Millisecond))))
+
+	assert.Eventually(t, func() bool { return len(sessions.List()) == 0 }, time.Second, 5*time.Millisecond)
+
+	done := s.reaperDone
+	require.NoError(t, s.Close())
+	select {
+	case <-done:
+	default:
+		t.Fatal("reaper is running after Close")
+	}
+	_, err = s.CreateCollection("tokens", &CollectionConfig{PrimaryKey: "id", ExpireField: "at"})
+	require.NoError(t, err)
+	assert.Nil(t, s.reaperStop, "closed store does not start the reaper again")
+}
+
+func TestDump_KeepsExpiry(t *testing.T) {
+	s := NewStore()
+	sessions, err := s.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", ExpireField: "at", ExpireAfter: time.Minute})
+	require.NoError(t, err)
+	require.NoError(t, sessions.Put(sessionDoc("s1", time.Now().Add(-2*time.Minute))))
+	require.NoError(t, sessions.Put(sessionDoc("s2", time.Now())))
+	dump, err := s.Dump()
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	restored, err := NewStoreFromDump(dump)
+	require.NoError(t, err)
+	defer restored.Close()
+	sessions, err = restored.GetCollection("sessions")
+	require.NoError(t, err)
+	_, err = sessions.Get("s1")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+	_, err = sessions.Get("s2")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, restored.expire(time.Now().Add(time.Minute)))
+}
+
+func TestOpenStore_ReplaysExpiry(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+	s, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	sessions, err := s.CreateCollection("sessions", &CollectionConfig{PrimaryKey: "id", ExpireField: "at"})
+	require.NoError(t, err)
+	at := time.Now().Add(time.Hour)
+	require.NoError(t, sessions.Put(sessionDoc("s1", at)))
+	require.NoError(t, sessions.Put(sessionDoc("s2", at.Add(time.Hour))))
+	assert.Equal(t, 1, s.expire(at))
+	require.NoError(t, s.Close())
+
+	reopened, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	defer reopened.Close()
+	sessions, err = reopened.GetCollection("sessions")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s2"}, ids(sessions.List()))
+}
+
+func TestCreateCollection_InvalidExpiry(t *testing.T) {
+	for name, cfg := range map[string]CollectionConfig{
+		"negative":      {PrimaryKey: "id", ExpireField: "at", ExpireAfter: -time.Second},
+		"without field": {PrimaryKey: "id", ExpireAfter: time.Second},
+		"bad path":      {PrimaryKey: "id", ExpireField: "a..b"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewStore().CreateCollection("c", &cfg)
+			assert.ErrorIs(t, err, ErrInvalidExpiry)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package documentstore
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// Helpers for the timestamp, binary, null and decimal field types.
+//
+// Decimals are exact: they are kept as *big.Rat and written as decimal
+// strings, so only values with a finite decimal expansion can be stored
+// (1/4 is 0.25, 1/3 is rejected).
+
+var ratType = reflect.TypeFor[big.Rat]()
+
+// goFieldType returns the field type MarshalDocument produces for values of
+// typ. Types implementing FieldMarshaler may choose another one.
+func goFieldType(typ reflect.Type) (DocumentFieldType, bool) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch {
+	case typ == timeType:
+		return DocumentFieldTypeTimestamp, true
+	case typ == ratType:
+		return DocumentFieldTypeDecimal, true
+	case typ.Implements(textMarshalerType), reflect.PointerTo(typ).Implements(textMarshalerType):
+		return DocumentFieldTypeString, true
+	case isBytesType(typ):
+		return DocumentFieldTypeBinary, true
+	}
+	return kindFieldType(typ.Kind())
+}
+
+func isBytesType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+}
+
+// orderType groups the types that compare with each other: decimals are
+// ordered together with numbers.
+func orderType(t DocumentFieldType) DocumentFieldType {
+	if t == DocumentFieldTypeDecimal {
+		return DocumentFieldTypeNumber
+	}
+	return t
+}
+
+// toRat converts a decimal or a number to *big.Rat. The result must not be
+// modified, it may be the stored value itself.
+func toRat(v any) (*big.Rat, bool) {
+	if f, ok := v.(DocumentField); ok {
+		v = f.Value
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Type() != reflect.PointerTo(ratType) {
+		rv = rv.Elem()
+	}
+	switch {
+	case !rv.IsValid():
+		return nil, false
+	case rv.Type() == reflect.PointerTo(ratType):
+		if rv.IsNil() {
+			return nil, false
+		}
+		return rv.Interface().(*big.Rat), true
+	case rv.Type() == ratType:
+		r := reflect.New(ratType)
+		r.Elem().Set(rv)
+		return new(big.Rat).Set(r.Interface().(*big.Rat)), true
+	case isIntKind(rv.Kind()):
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case isUintKind(rv.Kind()):
+		return new(big.Rat).SetUint64(rv.Uint()), true
+	case rv.Kind() == reflect.Float32 || rv.Kind() == reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(f), true
+	}
+	return nil, false
+}
+
+// decimalString formats r without losing precision. ok is false when r has
+// no finite decimal expansion.
+func decimalString(r *big.Rat) (string, bool) {
+	den := new(big.Int).Set(r.Denom())
+	var twos, fives int
+	two, five, rem := big.NewInt(2), big.NewInt(5), new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(den, two, rem)
+		if m.Sign() != 0 {
+			break
+		}
+		den, twos = q, twos+1
+	}
+	for {
+		q, m := new(big.Int).QuoRem(den, five, rem)
+		if m.Sign() != 0 {
+			break
+		}
+		den, fives = q, fives+1
+	}
+	if !den.IsInt64() || den.Int64() != 1 {
+		return "", false
+	}
+	return r.FloatString(max(twos, fives)), true
+}
+
+// parseDecimal parses a decimal string such as "12.50" or "-1e-3".
+func parseDecimal(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.Contains(s, "/") {
+		return nil, fmt.Errorf("%w: invalid decimal %q", ErrDocumentFieldTypeMismatch, s)
+	}
+	return r, nil
+}
@@ -0,0 +1,239 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentField_NewTypesJSONRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 10, 30, 0, 5, time.UTC)
+	tests := []struct {
+		name  string
+		field DocumentField
+		want  any
+		json  string
+	}{
+		{"timestamp", DocumentField{Type: DocumentFieldTypeTimestamp, Value: ts}, ts, `"2024-03-01T10:30:00.000000005Z"`},
+		{"binary", DocumentField{Type: DocumentFieldTypeBinary, Value: []byte{0, 1, 0xff}}, []byte{0, 1, 0xff}, `"AAH/"`},
+		{"decimal", DocumentField{Type: DocumentFieldTypeDecimal, Value: big.NewRat(1999, 100)}, big.NewRat(1999, 100), `"19.99"`},
+		{"null", DocumentField{Type: DocumentFieldTypeNull}, nil, `null`},
+		{"nested", DocumentField{Type: DocumentFieldTypeArray, Value: []any{ts, []byte("hi"), big.NewRat(-1, 8), nil}},
+			[]DocumentField{
+				{Type: DocumentFieldTypeTimestamp, Value: ts},
+				{Type: DocumentFieldTypeBinary, Value: []byte("hi")},
+				{Type: DocumentFieldTypeDecimal, Value: big.NewRat(-1, 8)},
+				{Type: DocumentFieldTypeNull},
+			},
+			`[{"$timestamp":"2024-03-01T10:30:00.000000005Z"},{"$binary":"aGk="},{"$decimal":"-0.125"},null]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.field)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"Type":"`+string(tt.field.Type)+`","Value":`+tt.json+`}`, string(data))
+
+			var decoded DocumentField
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, tt.field.Type, decoded.Type)
+			assert.Equal(t, tt.want, decoded.Value)
+		})
+	}
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		for _, data := range []string{
+			`{"Type":"timestamp","Value":"yesterday"}`,
+			`{"Type":"binary","Value":"%%"}`,
+			`{"Type":"decimal","Value":"1/3"}`,
+			`{"Type":"decimal","Value":1.5}`,
+			`{"Type":"null","Value":0}`,
+		} {
+			var f DocumentField
+			assert.ErrorIs(t, json.Unmarshal([]byte(data), &f), ErrDocumentFieldTypeMismatch, data)
+		}
+	})
+}
+
+func TestPut_ValidatesFieldValues(t *testing.T) {
+	s := NewStore()
+	coll, err := s.CreateCollection("events", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		field DocumentField
+	}{
+		{"timestamp as string", DocumentField{Type: DocumentFieldTypeTimestamp, Value: "2024-03-01"}},
+		{"binary as string", DocumentField{Type: DocumentFieldTypeBinary, Value: "AAH/"}},
+		{"decimal as float", DocumentField{Type: DocumentFieldTypeDecimal, Value: 1.5}},
+		{"null with value", DocumentField{Type: DocumentFieldTypeNull, Value: 0}},
+		{"number as string", DocumentField{Type: DocumentFieldTypeNumber, Value: "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := coll.Put(Document{Fields: map[string]DocumentField{
+				"id":    {Type: DocumentFieldTypeString, Value: "e1"},
+				"value": tt.field,
+			}})
+			assert.ErrorIs(t, err, ErrDocumentFieldTypeMismatch)
+		})
+	}
+
+	err = coll.Put(Document{Fields: map[string]DocumentField{
+		"id":    {Type: DocumentFieldTypeString, Value: "e1"},
+		"value": {Type: DocumentFieldTypeDecimal, Value: big.NewRat(1, 3)},
+	}})
+	assert.ErrorIs(t, err, ErrUnsupportedDocumentField, "1/3 has no finite decimal form")
+	assert.Empty(t, coll.List())
+}
+
+func newPaymentsStore(t *testing.T, indexes ...IndexConfig) (*Store, *Collection) {
+	t.Helper()
+	s := NewStore()
+	coll, err := s.CreateCollection("payments", &CollectionConfig{PrimaryKey: "id", Indexes: indexes})
+	require.NoError(t, err)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	payments := []struct {
+		id     string
+		amount any
+		at     time.Time
+	}{
+		{"p1", big.NewRat(1050, 100), day},
+		{"p2", 3, day.Add(time.Hour)},
+		{"p3", big.NewRat(3, 1), day.Add(2 * time.Hour)},
+		{"p4", 20.25, day.Add(3 * time.Hour)},
+	}
+	for _, p := range payments {
+		amount, ok := fieldFromValue(p.amount)
+		require.True(t, ok)
+		require.NoError(t, coll.Put(Document{Fields: map[string]DocumentField{
+			"id":     {Type: DocumentFieldTypeString, Value: p.id},
+			"amount": amount,
+			"at":     {Type: DocumentFieldTypeTimestamp, Value: p.at},
+			"refund": {Type: DocumentFieldTypeNull},
+		}}))
+	}
+	return s, coll
+}
+
+func TestFind_NewFieldTypes(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	kyiv := time.FixedZone("EET", 2*60*60)
+	for name, indexes := range map[string][]IndexConfig{
+		"scan":    nil,
+		"hash":    {{Field: "amount", Type: IndexTypeHash}, {Field: "at", Type: IndexTypeHash}},
+		"ordered": {{Field: "amount", Type: IndexTypeOrdered}, {Field: "at", Type: IndexTypeOrdered}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, coll := newPaymentsStore(t, indexes...)
+			tests := []struct {
+				name   string
+				filter Filter
+				want   []string
+			}{
+				{"decimal equals number", Eq("amount", 3), []string{"p2", "p3"}},
+				{"number equals decimal", Eq("amount", big.NewRat(21, 2)), []string{"p1"}},
+				{"decimal range", Gt("amount", big.NewRat(3, 1)), []string{"p1", "p4"}},
+				{"timestamp in another zone", Eq("at", day.Add(time.Hour).In(kyiv)), []string{"p2"}},
+				{"timestamp range", Gte("at", day.Add(2*time.Hour)), []string{"p3", "p4"}},
+				{"null", Eq("refund", nil), []string{"p1", "p2", "p3", "p4"}},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					docs, err := coll.Find(tt.filter)
+					require.NoError(t, err)
+					assert.ElementsMatch(t, tt.want, ids(docs))
+				})
+			}
+		})
+	}
+}
+
+func TestListWithOptions_SortsDecimalsWithNumbers(t *testing.T) {
+	_, coll := newPaymentsStore(t)
+	res, err := coll.ListWithOptions(ListOptions{Sort: []SortKey{{Field: "amount", Order: SortDesc}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p4", "p1", "p2", "p3"}, ids(res.Documents))
+}
+
+func TestUpdate_IncDecimal(t *testing.T) {
+	_, coll := newPaymentsStore(t)
+	doc, err := coll.Update("p1", Inc("amount", 2))
+	require.NoError(t, err)
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeDecimal, Value: big.NewRat(1250, 100)}, doc.Fields["amount"])
+}
+
+func TestDump_NewFieldTypes(t *testing.T) {
+	s, coll := newPaymentsStore(t)
+	require.NoError(t, coll.Put(Document{Fields: map[string]DocumentField{
+		"id":   {Type: DocumentFieldTypeString, Value: "p5"},
+		"hash": {Type: DocumentFieldTypeBinary, Value: []byte{0xde, 0xad}},
+		"meta": {Type: DocumentFieldTypeObject, Value: map[string]any{"fee": big.NewRat(1, 4)}},
+	}}))
+
+	dump, err := s.Dump()
+	require.NoError(t, err)
+	restored, err := NewStoreFromDump(dump)
+	require.NoError(t, err)
+	rc, err := restored.GetCollection("payments")
+	require.NoError(t, err)
+
+	p1, err := rc.Get("p1")
+	require.NoError(t, err)
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeDecimal, Value: big.NewRat(21, 2)}, p1.Fields["amount"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeTimestamp, Value: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, p1.Fields["at"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeNull}, p1.Fields["refund"])
+
+	p5, err := rc.Get("p5")
+	require.NoError(t, err)
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeBinary, Value: []byte{0xde, 0xad}}, p5.Fields["hash"])
+	assert.Equal(t, map[string]DocumentField{"fee": {Type: DocumentFieldTypeDecimal, Value: big.NewRat(1, 4)}}, p5.Fields["meta"].Value)
+}
+
+type invoice struct {
+	ID       string     `doc:"id"`
+	Total    big.Rat    `doc:"total"`
+	Tax      *big.Rat   `doc:"tax"`
+	IssuedAt time.Time  `doc:"issuedAt"`
+	PaidAt   *time.Time `doc:"paidAt"`
+	PDF      []byte     `doc:"pdf"`
+}
+
+func TestMarshalDocument_NewFieldTypes(t *testing.T) {
+	issued := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	in := invoice{ID: "i1", Tax: big.NewRat(2, 5), IssuedAt: issued, PDF: []byte("%PDF")}
+	in.Total.SetFrac64(12345, 100)
+
+	doc, err := MarshalDocument(in)
+	require.NoError(t, err)
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeDecimal, Value: big.NewRat(12345, 100)}, doc.Fields["total"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeDecimal, Value: big.NewRat(2, 5)}, doc.Fields["tax"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeTimestamp, Value: issued}, doc.Fields["issuedAt"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeNull}, doc.Fields["paidAt"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeBinary, Value: []byte("%PDF")}, doc.Fields["pdf"])
+
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var stored Document
+	require.NoError(t, json.Unmarshal(data, &stored))
+
+	var out invoice
+	require.NoError(t, UnmarshalDocument(&stored, &out, Strict()))
+	assert.Equal(t, "123.45", out.Total.FloatString(2))
+	assert.Equal(t, 0, out.Tax.Cmp(in.Tax))
+	assert.True(t, out.IssuedAt.Equal(issued))
+	assert.Nil(t, out.PaidAt)
+	assert.Equal(t, in.PDF, out.PDF)
+
+	t.Run("strict types reject other field types", func(t *testing.T) {
+		stored.Fields["issuedAt"] = DocumentField{Type: DocumentFieldTypeNumber, Value: int64(1)}
+		err := UnmarshalDocument(&stored, &out, StrictTypes())
+		var uerr *UnmarshalError
+		require.ErrorAs(t, err, &uerr)
+		assert.Equal(t, "issuedAt", uerr.Fields[0].Path)
+	})
+}
@@ -0,0 +1,343 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidIndex = errors.New("[Collection] Error: invalid index config")
+)
+
+type IndexType string
+
+const (
+	// IndexTypeHash serves equality lookups ($eq, $in).
+	IndexTypeHash IndexType = "hash"
+	// IndexTypeOrdered serves equality and range lookups ($gt, $gte, $lt, $lte).
+	IndexTypeOrdered IndexType = "ordered"
+)
+
+type IndexConfig struct {
+	Field string
+	Type  IndexType
+}
+
+// keySet is a set of primary keys returned by index lookups.
+type keySet map[string]struct{}
+
+// index is a secondary index over one document field.
+// All methods are called with the collection lock held.
+type index interface {
+	add(key string, doc *Document)
+	remove(key string, doc *Document)
+	// lookup returns the keys of documents that may match a field filter.
+	// ok is false when the index cannot serve the filter.
+	lookup(f Filter) (keys keySet, ok bool)
+}
+
+func validateIndexes(indexes []IndexConfig) error {
+	seen := make(map[string]bool, len(indexes))
+	for _, ic := range indexes {
+		if strings.TrimSpace(ic.Field) == "" {
+			return fmt.Errorf("%w: index field is empty", ErrInvalidIndex)
+		}
+		if ic.Type != IndexTypeHash && ic.Type != IndexTypeOrdered {
+			return fmt.Errorf("%w: unknown index type %q for field %s", ErrInvalidIndex, ic.Type, ic.Field)
+		}
+		if seen[ic.Field] {
+			return fmt.Errorf("%w: duplicate index for field %s", ErrInvalidIndex, ic.Field)
+		}
+		seen[ic.Field] = true
+	}
+	return nil
+}
+
+func newIndex(ic IndexConfig) index {
+	if ic.Type == IndexTypeOrdered {
+		return &orderedIndex{field: ic.Field}
+	}
+	return &hashIndex{field: ic.Field, entries: make(map[string]keySet)}
+}
+
+// hashKey builds a canonical string for a field value, so that equal values
+// (for example int 30 and float64 30) produce the same key.
+func hashKey(f DocumentField) (string, bool) {
+	var s string
+	switch f.Type {
+	case DocumentFieldTypeNumber:
+		v := reflect.ValueOf(f.Value)
+		if !isNumberKind(v) {
+			return "", false
+		}
+		switch {
+		case isIntKind(v.Kind()):
+			s = strconv.FormatInt(v.Int(), 10)
+		case isUintKind(v.Kind()):
+			s = strconv.FormatUint(v.Uint(), 10)
+		default:
+			fl := v.Float()
+			if fl == math.Trunc(fl) && math.Abs(fl) < math.MaxInt64 {
+				s = strconv.FormatInt(int64(fl), 10)
+			} else {
+				s = strconv.FormatFloat(fl, 'g', -1, 64)
+			}
+		}
+	case DocumentFieldTypeDecimal:
+		// decimals hash like numbers of the same value
+		r, ok := toRat(f.Value)
+		if !ok {
+			return "", false
+		}
+		if r.IsInt() && r.Num().IsInt64() {
+			s = strconv.FormatInt(r.Num().Int64(), 10)
+		} else {
+			fl, _ := r.Float64()
+			s = strconv.FormatFloat(fl, 'g', -1, 64)
+		}
+	case DocumentFieldTypeTimestamp:
+		// equal instants in different locations hash the same
+		t, ok := f.Value.(time.Time)
+		if !ok {
+			return "", false
+		}
+		s = t.UTC().Format(time.RFC3339Nano)
+	default:
+		// arrays and objects may be plain Go values or DocumentField trees,
+		// the canonical form makes both hash the same
+		value, err := canonicalFieldValue(f.Type, f.Value)
+		if err != nil {
+			return "", false
+		}
+		data, err := json.Marshal(encodeCanonical(value, false))
+		if err != nil {
+			return "", false
+		}
+		s = string(data)
+	}
+	return string(orderType(f.Type)) + ":" + s, true
+}
+
+type hashIndex struct {
+	field   string
+	entries map[string]keySet
+}
+
+func (ix *hashIndex) add(key string, doc *Document) {
+	field, ok := lookupField(doc, ix.field)
+	if !ok {
+		return
+	}
+	hk, ok := hashKey(field)
+	if !ok {
+		return
+	}
+	if ix.entries[hk] == nil {
+		ix.entries[hk] = make(keySet)
+	}
+	ix.entries[hk][key] = struct{}{}
+}
+
+func (ix *hashIndex) remove(key string, doc *Document) {
+	field, ok := lookupField(doc, ix.field)
+	if !ok {
+		return
+	}
+	hk, ok := hashKey(field)
+	if !ok {
+		return
+	}
+	delete(ix.entries[hk], key)
+	if len(ix.entries[hk]) == 0 {
+		delete(ix.entries, hk)
+	}
+}
+
+func (ix *hashIndex) lookup(f Filter) (keySet, bool) {
+	var values []any
+	switch f.Op {
+	case FilterOpEq:
+		values = []any{f.Value}
+	case FilterOpIn:
+		values, _ = f.Value.([]any)
+	default:
+		return nil, false
+	}
+	keys := make(keySet)
+	for _, v := range values {
+		field, ok := fieldFromValue(v)
+		if !ok {
+			return nil, false
+		}
+		hk, ok := hashKey(field)
+		if !ok {
+			return nil, false
+		}
+		for k := range ix.entries[hk] {
+			keys[k] = struct{}{}
+		}
+	}
+	return keys, true
+}
+
+type orderedEntry struct {
+	value DocumentField
+	key   string
+}
+
+// orderedIndex keeps entries sorted by field type, then value, then primary key.
+// Decimals are sorted together with numbers. Only fields of ordered types
+// (everything except arrays and objects) are indexed.
+type orderedIndex struct {
+	field   string
+	entries []orderedEntry
+}
+
+func isOrderable(f DocumentField) bool {
+	_, ok := compareFields(f, f)
+	return ok
+}
+
+func (ix *orderedIndex) less(a, b orderedEntry) bool {
+	if at, bt := orderType(a.value.Type), orderType(b.value.Type); at != bt {
+		return at < bt
+	}
+	if c, _ := compareFields(a.value, b.value); c != 0 {
+		return c < 0
+	}
+	return a.key < b.key
+}
+
+func (ix *orderedIndex) add(key string, doc *Document) {
+	field, ok := lookupField(doc, ix.field)
+	if !ok || !isOrderable(field) {
+		return
+	}
+	e := orderedEntry{value: field, key: key}
+	pos := sort.Search(len(ix.entries), func(i int) bool { return !ix.less(ix.entries[i], e) })
+	ix.entries = append(ix.entries, orderedEntry{})
+	copy(ix.entries[pos+1:], ix.entries[pos:])
+	ix.entries[pos] = e
+}
+
+func (ix *orderedIndex) remove(key string, doc *Document) {
+	field, ok := lookupField(doc, ix.field)
+	if !ok || !isOrderable(field) {
+		return
+	}
+	e := orderedEntry{value: field, key: key}
+	pos := sort.Search(len(ix.entries), func(i int) bool { return !ix.less(ix.entries[i], e) })
+	if pos < len(ix.entries) && ix.entries[pos].key == key {
+		ix.entries = append(ix.entries[:pos], ix.entries[pos+1:]...)
+	}
+}
+
+// bound returns the first position whose entry is past v: with strict=false
+// the first entry >= v, with strict=true the first entry > v.
+func (ix *orderedIndex) bound(v DocumentField, strict bool) int {
+	return sort.Search(len(ix.entries), func(i int) bool {
+		e := ix.entries[i].value
+		if et, vt := orderType(e.Type), orderType(v.Type); et != vt {
+			return et > vt
+		}
+		c, _ := compareFields(e, v)
+		if strict {
+			return c > 0
+		}
+		return c >= 0
+	})
+}
+
+func (ix *orderedIndex) typeRange(t DocumentFieldType) (int, int) {
+	t = orderType(t)
+	start := sort.Search(len(ix.entries), func(i int) bool { return orderType(ix.entries[i].value.Type) >= t })
+	end := sort.Search(len(ix.entries), func(i int) bool { return orderType(ix.entries[i].value.Type) > t })
+	return start, end
+}
+
+func (ix *orderedIndex) lookup(f Filter) (keySet, bool) {
+	values := []any{f.Value}
+	if f.Op == FilterOpIn {
+		values, _ = f.Value.([]any)
+	}
+	keys := make(keySet)
+	for _, raw := range values {
+		v, ok := fieldFromValue(raw)
+		if !ok || !isOrderable(v) {
+			return nil, false
+		}
+		typeStart, typeEnd := ix.typeRange(v.Type)
+		var from, to int
+		switch f.Op {
+		case FilterOpEq, FilterOpIn:
+			from, to = ix.bound(v, false), ix.bound(v, true)
+		case FilterOpGt:
+			from, to = ix.bound(v, true), typeEnd
+		case FilterOpGte:
+			from, to = ix.bound(v, false), typeEnd
+		case FilterOpLt:
+			from, to = typeStart, ix.bound(v, false)
+		case FilterOpLte:
+			from, to = typeStart, ix.bound(v, true)
+		default:
+			return nil, false
+		}
+		for _, e := range ix.entries[from:to] {
+			keys[e.key] = struct{}{}
+		}
+	}
+	return keys, true
+}
+
+// candidates narrows a filter down to a set of primary keys using the
+// collection indexes. ok is false when a full scan is required.
+// Must be called with the collection lock held.
+func (s *Collection) candidates(f Filter) (keySet, bool) {
+	switch f.Op {
+	case FilterOpAnd:
+		var result keySet
+		for _, sub := range f.Filters {
+			keys, ok := s.candidates(sub)
+			if !ok {
+				continue
+			}
+			if result == nil {
+				result = keys
+				continue
+			}
+			for k := range result {
+				if _, found := keys[k]; !found {
+					delete(result, k)
+				}
+			}
+		}
+		return result, result != nil
+	case FilterOpOr:
+		if len(f.Filters) == 0 {
+			return nil, false
+		}
+		result := make(keySet)
+		for _, sub := range f.Filters {
+			keys, ok := s.candidates(sub)
+			if !ok {
+				return nil, false
+			}
+			for k := range keys {
+				result[k] = struct{}{}
+			}
+		}
+		return result, true
+	}
+	ix, ok := s.indexes[f.Field]
+	if !ok {
+		return nil, false
+	}
+	return ix.lookup(f)
+}
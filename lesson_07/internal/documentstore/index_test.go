@@ -0,0 +1,159 @@
+package documentstore
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIndexedCollection(t *testing.T) *Collection {
+	t.Helper()
+	col := NewCollection(&CollectionConfig{
+		PrimaryKey: "id",
+		Indexes: []IndexConfig{
+			{Field: "status", Type: IndexTypeHash},
+			{Field: "age", Type: IndexTypeOrdered},
+		},
+	})
+	for id, age := range map[string]int{"u1": 17, "u2": 25, "u3": 31, "u4": 45} {
+		status := "active"
+		if id == "u3" {
+			status = "blocked"
+		}
+		require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+			"id":     {Type: DocumentFieldTypeString, Value: id},
+			"age":    {Type: DocumentFieldTypeNumber, Value: age},
+			"status": {Type: DocumentFieldTypeString, Value: status},
+		}}))
+	}
+	return col
+}
+
+func keysOf(set keySet) []string {
+	result := make([]string, 0, len(set))
+	for k := range set {
+		result = append(result, k)
+	}
+	return result
+}
+
+func TestIndex_Candidates(t *testing.T) {
+	col := newIndexedCollection(t)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+		usable bool
+	}{
+		{"hash eq", Eq("status", "blocked"), []string{"u3"}, true},
+		{"hash in", In("status", "active"), []string{"u1", "u2", "u4"}, true},
+		{"ordered eq float", Eq("age", 25.0), []string{"u2"}, true},
+		{"ordered gt", Gt("age", 25), []string{"u3", "u4"}, true},
+		{"ordered gte", Gte("age", 25), []string{"u2", "u3", "u4"}, true},
+		{"ordered lt", Lt("age", 25), []string{"u1"}, true},
+		{"ordered lte", Lte("age", 31), []string{"u1", "u2", "u3"}, true},
+		{"ordered other type", Gt("age", "a"), []string{}, true},
+		{"and intersects", And(Eq("status", "active"), Gt("age", 20)), []string{"u2", "u4"}, true},
+		{"or unions", Or(Eq("status", "blocked"), Lt("age", 18)), []string{"u1", "u3"}, true},
+		{"hash range not usable", Gt("status", "a"), nil, false},
+		{"unindexed field", Eq("name", "x"), nil, false},
+		{"or with unindexed", Or(Eq("status", "blocked"), Eq("name", "x")), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, ok := col.candidates(tt.filter)
+			assert.Equal(t, tt.usable, ok)
+			if tt.usable {
+				assert.ElementsMatch(t, tt.want, keysOf(keys))
+			}
+		})
+	}
+}
+
+func TestIndex_MaintainedOnPutAndDelete(t *testing.T) {
+	col := newIndexedCollection(t)
+
+	// u3 is unblocked and gets older
+	require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+		"id":     {Type: DocumentFieldTypeString, Value: "u3"},
+		"age":    {Type: DocumentFieldTypeNumber, Value: 50},
+		"status": {Type: DocumentFieldTypeString, Value: "active"},
+	}}))
+	require.NoError(t, col.Delete("u4"))
+
+	docs, err := col.Find(Eq("status", "blocked"))
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+
+	docs, err = col.Find(Gte("age", 31))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"u3"}, ids(docs))
+
+	keys, _ := col.candidates(Eq("status", "active"))
+	assert.ElementsMatch(t, []string{"u1", "u2", "u3"}, keysOf(keys))
+}
+
+func TestIndex_ConcurrentAccess(t *testing.T) {
+	col := newIndexedCollection(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_ = col.Put(Document{Fields: map[string]DocumentField{
+				"id":  {Type: DocumentFieldTypeString, Value: "u2"},
+				"age": {Type: DocumentFieldTypeNumber, Value: i},
+			}})
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, _ = col.Find(Gte("age", 0))
+		}()
+	}
+	wg.Wait()
+
+	docs, err := col.Find(Gte("age", 0))
+	require.NoError(t, err)
+	assert.Len(t, docs, 4)
+}
+
+func TestIndex_RebuiltFromDump(t *testing.T) {
+	s := NewStore()
+	col, err := s.CreateCollection("users", &CollectionConfig{
+		PrimaryKey: "id",
+		Indexes:    []IndexConfig{{Field: "age", Type: IndexTypeOrdered}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+		"id":  {Type: DocumentFieldTypeString, Value: "u1"},
+		"age": {Type: DocumentFieldTypeNumber, Value: 30},
+	}}))
+
+	data, err := s.Dump()
+	require.NoError(t, err)
+	s2, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+	col2, err := s2.GetCollection("users")
+	require.NoError(t, err)
+
+	keys, ok := col2.candidates(Gt("age", 18))
+	require.True(t, ok)
+	assert.ElementsMatch(t, []string{"u1"}, keysOf(keys))
+}
+
+func TestCreateCollection_InvalidIndexes(t *testing.T) {
+	s := NewStore()
+	invalid := [][]IndexConfig{
+		{{Field: "", Type: IndexTypeHash}},
+		{{Field: "age", Type: "btree"}},
+		{{Field: "age", Type: IndexTypeHash}, {Field: "age", Type: IndexTypeOrdered}},
+	}
+	for _, indexes := range invalid {
+		_, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Indexes: indexes})
+		assert.ErrorIs(t, err, ErrInvalidIndex)
+	}
+}
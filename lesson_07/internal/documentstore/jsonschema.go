@@ -0,0 +1,245 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ParseJSONSchema converts a JSON Schema (draft 2020-12) describing documents
+// into a Schema for CollectionConfig. The supported subset is:
+//
+//   - type: string, number, integer, boolean, array, object, null,
+//     or a list of one of them and "null"
+//   - properties, required, additionalProperties (true or false)
+//   - items, enum, pattern
+//   - minimum, maximum, minLength, maxLength, minItems, maxItems
+//
+// Annotations such as title, description and format are ignored. Any other
+// keyword is rejected, so a contract is never enforced only in part.
+// As in JSON Schema, objects accept unknown properties unless
+// additionalProperties is false.
+func ParseJSONSchema(data []byte) (*Schema, error) {
+	var root map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	fs, err := convertJSONSchema("", root)
+	if err != nil {
+		return nil, err
+	}
+	if fs.Type != DocumentFieldTypeObject {
+		return nil, fmt.Errorf("%w: root schema must describe an object", ErrInvalidSchema)
+	}
+	schema := fs.Object
+	if schema == nil {
+		schema = &Schema{Fields: map[string]*FieldSchema{}, AllowUnknown: true}
+	}
+	if _, err := compileSchema(schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+var jsonSchemaAnnotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+	"format": true,
+}
+
+var jsonSchemaTypes = map[string]DocumentFieldType{
+	"string":  DocumentFieldTypeString,
+	"number":  DocumentFieldTypeNumber,
+	"integer": DocumentFieldTypeNumber,
+	"boolean": DocumentFieldTypeBool,
+	"array":   DocumentFieldTypeArray,
+	"object":  DocumentFieldTypeObject,
+	"null":    DocumentFieldTypeNull,
+}
+
+// convertJSONSchema converts one schema node, path is its JSON pointer.
+func convertJSONSchema(path string, node map[string]any) (*FieldSchema, error) {
+	invalid := func(keyword, format string, args ...any) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidSchema, pointerPath(path, keyword), fmt.Sprintf(format, args...))
+	}
+	fs := &FieldSchema{}
+	if raw, ok := node["type"]; ok {
+		var names []string
+		switch t := raw.(type) {
+		case string:
+			names = []string{t}
+		case []any:
+			for _, elem := range t {
+				name, ok := elem.(string)
+				if !ok {
+					return nil, invalid("type", "expected a string, got %v", elem)
+				}
+				if name == "null" && len(t) > 1 {
+					fs.Nullable = true
+					continue
+				}
+				names = append(names, name)
+			}
+		default:
+			return nil, invalid("type", "expected a string or a list, got %v", raw)
+		}
+		if len(names) != 1 {
+			return nil, invalid("type", "unions other than a type and null are not supported")
+		}
+		t, ok := jsonSchemaTypes[names[0]]
+		if !ok {
+			return nil, invalid("type", "unknown type %q", names[0])
+		}
+		fs.Type = t
+		fs.Integer = names[0] == "integer"
+	}
+
+	keywords := make([]string, 0, len(node))
+	for keyword := range node {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		raw := node[keyword]
+		switch keyword {
+		case "type":
+		case "properties":
+			props, ok := raw.(map[string]any)
+			if !ok {
+				return nil, invalid(keyword, "expected an object")
+			}
+			obj, err := jsonSchemaObject(fs, path, keyword)
+			if err != nil {
+				return nil, err
+			}
+			for name, sub := range props {
+				subPath := pointerPath(pointerPath(path, keyword), name)
+				field, err := jsonSubschema(subPath, sub)
+				if err != nil {
+					return nil, err
+				}
+				if existing := obj.Fields[name]; existing != nil {
+					field.Required = existing.Required
+				}
+				obj.Fields[name] = field
+			}
+		case "required":
+			names, ok := raw.([]any)
+			if !ok {
+				return nil, invalid(keyword, "expected a list of property names")
+			}
+			obj, err := jsonSchemaObject(fs, path, keyword)
+			if err != nil {
+				return nil, err
+			}
+			for _, elem := range names {
+				name, ok := elem.(string)
+				if !ok {
+					return nil, invalid(keyword, "expected a property name, got %v", elem)
+				}
+				if obj.Fields[name] == nil {
+					obj.Fields[name] = &FieldSchema{}
+				}
+				obj.Fields[name].Required = true
+			}
+		case "additionalProperties":
+			allowed, ok := raw.(bool)
+			if sub, isSchema := raw.(map[string]any); isSchema && len(sub) == 0 {
+				allowed, ok = true, true
+			}
+			if !ok {
+				return nil, invalid(keyword, "only true and false are supported")
+			}
+			obj, err := jsonSchemaObject(fs, path, keyword)
+			if err != nil {
+				return nil, err
+			}
+			obj.AllowUnknown = allowed
+		case "items":
+			if fs.Type != DocumentFieldTypeArray {
+				return nil, invalid(keyword, "requires type array")
+			}
+			items, err := jsonSubschema(pointerPath(path, keyword), raw)
+			if err != nil {
+				return nil, err
+			}
+			fs.Items = items
+		case "enum":
+			values, ok := raw.([]any)
+			if !ok || len(values) == 0 {
+				return nil, invalid(keyword, "expected a non-empty list")
+			}
+			for _, v := range values {
+				f, err := inferField(v)
+				if err != nil {
+					return nil, invalid(keyword, "%v", err)
+				}
+				fs.Enum = append(fs.Enum, f)
+			}
+		case "pattern":
+			pattern, ok := raw.(string)
+			if !ok {
+				return nil, invalid(keyword, "expected a string")
+			}
+			fs.Pattern = pattern
+		case "minimum", "maximum", "minLength", "maxLength", "minItems", "maxItems":
+			n, ok := raw.(json.Number)
+			if !ok {
+				return nil, invalid(keyword, "expected a number")
+			}
+			bound, err := n.Float64()
+			if err != nil {
+				return nil, invalid(keyword, "%v", err)
+			}
+			want := map[string]DocumentFieldType{
+				"minimum": DocumentFieldTypeNumber, "maximum": DocumentFieldTypeNumber,
+				"minLength": DocumentFieldTypeString, "maxLength": DocumentFieldTypeString,
+				"minItems": DocumentFieldTypeArray, "maxItems": DocumentFieldTypeArray,
+			}[keyword]
+			if fs.Type != want {
+				return nil, invalid(keyword, "requires type %s", want)
+			}
+			if keyword[:3] == "min" {
+				fs.Min = &bound
+			} else {
+				fs.Max = &bound
+			}
+		default:
+			if !jsonSchemaAnnotations[keyword] {
+				return nil, invalid(keyword, "unsupported keyword")
+			}
+		}
+	}
+	return fs, nil
+}
+
+// jsonSchemaObject returns the object schema of fs, creating it on first use.
+func jsonSchemaObject(fs *FieldSchema, path, keyword string) (*Schema, error) {
+	switch fs.Type {
+	case "":
+		fs.Type = DocumentFieldTypeObject
+	case DocumentFieldTypeObject:
+	default:
+		return nil, fmt.Errorf("%w: %s: requires type object", ErrInvalidSchema, pointerPath(path, keyword))
+	}
+	if fs.Object == nil {
+		fs.Object = &Schema{Fields: make(map[string]*FieldSchema), AllowUnknown: true}
+	}
+	return fs.Object, nil
+}
+
+// jsonSubschema converts a schema node that may also be the boolean schema true.
+func jsonSubschema(path string, raw any) (*FieldSchema, error) {
+	switch node := raw.(type) {
+	case map[string]any:
+		return convertJSONSchema(path, node)
+	case bool:
+		if node {
+			return &FieldSchema{}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s: expected a schema object or true", ErrInvalidSchema, path)
+}
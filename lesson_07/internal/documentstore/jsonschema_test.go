@@ -0,0 +1,124 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const orderJSONSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"title": "Order",
+	"type": "object",
+	"required": ["id", "status", "items"],
+	"additionalProperties": false,
+	"properties": {
+		"id":     {"type": "string", "pattern": "^o-[0-9]+$"},
+		"status": {"enum": ["new", "paid", "shipped"]},
+		"note":   {"type": ["string", "null"], "maxLength": 20},
+		"total":  {"type": "number", "minimum": 0},
+		"items": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["sku", "qty"],
+				"properties": {
+					"sku": {"type": "string", "description": "stock keeping unit"},
+					"qty": {"type": "integer", "minimum": 1, "maximum": 100}
+				}
+			}
+		},
+		"shipping": {
+			"type": "object",
+			"properties": {"address/line": {"type": "string"}}
+		}
+	}
+}`
+
+func orderItem(sku string, qty any) map[string]any {
+	return map[string]any{"sku": sku, "qty": qty}
+}
+
+func TestParseJSONSchema_Put(t *testing.T) {
+	schema, err := ParseJSONSchema([]byte(orderJSONSchema))
+	require.NoError(t, err)
+	orders, err := NewStore().CreateCollection("orders", &CollectionConfig{PrimaryKey: "id", Schema: schema})
+	require.NoError(t, err)
+
+	valid := Document{Fields: map[string]DocumentField{
+		"id":       {Type: DocumentFieldTypeString, Value: "o-1"},
+		"status":   {Type: DocumentFieldTypeString, Value: "new"},
+		"note":     {Type: DocumentFieldTypeNull},
+		"total":    {Type: DocumentFieldTypeNumber, Value: 19.5},
+		"items":    {Type: DocumentFieldTypeArray, Value: []any{orderItem("a", 2), orderItem("b", 1)}},
+		"shipping": {Type: DocumentFieldTypeObject, Value: map[string]any{"address/line": "Main st", "floor": 3}},
+	}}
+	require.NoError(t, orders.Put(valid))
+
+	err = orders.Put(Document{Fields: map[string]DocumentField{
+		"id":       {Type: DocumentFieldTypeString, Value: "o-2"},
+		"status":   {Type: DocumentFieldTypeString, Value: "lost"},
+		"note":     {Type: DocumentFieldTypeString, Value: "please ring the bell twice"},
+		"total":    {Type: DocumentFieldTypeNumber, Value: -1},
+		"items":    {Type: DocumentFieldTypeArray, Value: []any{orderItem("a", 1.5), map[string]any{"qty": 0}}},
+		"shipping": {Type: DocumentFieldTypeObject, Value: map[string]any{"address/line": 1}},
+		"coupon":   {Type: DocumentFieldTypeString, Value: "FREE"},
+	}})
+	require.ErrorIs(t, err, ErrSchemaViolation)
+	var serr *SchemaError
+	require.ErrorAs(t, err, &serr)
+	paths := make([]string, len(serr.Fields))
+	for i, f := range serr.Fields {
+		paths[i] = f.Path
+	}
+	assert.Equal(t, []string{
+		"/coupon",
+		"/items/0/qty",
+		"/items/1/qty",
+		"/items/1/sku",
+		"/note",
+		"/shipping/address~1line",
+		"/status",
+		"/total",
+	}, paths)
+
+	t.Run("empty array", func(t *testing.T) {
+		doc := Document{Fields: map[string]DocumentField{
+			"id":     {Type: DocumentFieldTypeString, Value: "o-3"},
+			"status": {Type: DocumentFieldTypeString, Value: "paid"},
+			"items":  {Type: DocumentFieldTypeArray, Value: []any{}},
+		}}
+		err := orders.Put(doc)
+		require.ErrorAs(t, err, &serr)
+		assert.Equal(t, []FieldError{{Path: "/items", Kind: FieldErrorInvalid, Expected: ">= 1", Actual: "[]"}}, serr.Fields)
+	})
+}
+
+func TestParseJSONSchema_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"not json", `{`, "unexpected EOF"},
+		{"root is not an object", `{"type": "string"}`, "root schema must describe an object"},
+		{"unsupported keyword", `{"type": "object", "properties": {"a": {"type": "string", "format": "email", "minContains": 1}}}`,
+			"/properties/a/minContains: unsupported keyword"},
+		{"ref", `{"properties": {"a": {"$ref": "#/$defs/a"}}}`, "/properties/a/$ref: unsupported keyword"},
+		{"type union", `{"properties": {"a": {"type": ["string", "number"]}}}`, "/properties/a/type: unions"},
+		{"unknown type", `{"properties": {"a": {"type": "date"}}}`, `unknown type "date"`},
+		{"additionalProperties schema", `{"additionalProperties": {"type": "string"}}`, "/additionalProperties: only true and false"},
+		{"minimum on string", `{"properties": {"a": {"type": "string", "minimum": 1}}}`, "/properties/a/minimum: requires type number"},
+		{"bad pattern", `{"properties": {"a": {"type": "string", "pattern": "("}}}`, "/a: error parsing regexp"},
+		{"false schema", `{"properties": {"a": false}}`, "/properties/a: expected a schema object or true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJSONSchema([]byte(tt.schema))
+			require.ErrorIs(t, err, ErrInvalidSchema)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
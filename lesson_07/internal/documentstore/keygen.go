@@ -0,0 +1,172 @@
+package documentstore
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrUnknownKeyStrategy = errors.New("[Collection] Error: unknown key strategy")
+)
+
+// KeyStrategy tells Collection.Insert how to generate a missing primary key.
+type KeyStrategy string
+
+const (
+	// KeyStrategyNone leaves the primary key to the caller.
+	KeyStrategyNone KeyStrategy = ""
+	// KeyStrategyUUIDv4 generates random UUIDs.
+	KeyStrategyUUIDv4 KeyStrategy = "uuidv4"
+	// KeyStrategyUUIDv7 generates UUIDs that start with a millisecond timestamp.
+	KeyStrategyUUIDv7 KeyStrategy = "uuidv7"
+	// KeyStrategyULID generates ULIDs, 26 characters of Crockford base32.
+	KeyStrategyULID KeyStrategy = "ulid"
+	// KeyStrategySequence generates "1", "2", ... from a per-collection counter
+	// that is stored in dumps and never goes back.
+	KeyStrategySequence KeyStrategy = "sequence"
+)
+
+func validateKeyStrategy(strategy KeyStrategy) error {
+	switch strategy {
+	case KeyStrategyNone, KeyStrategyUUIDv4, KeyStrategyUUIDv7, KeyStrategyULID, KeyStrategySequence:
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownKeyStrategy, strategy)
+}
+
+// Insert stores a new document and returns its primary key. When the document
+// has no primary key field it is generated with the KeyStrategy of the
+// collection, the fields of doc are not modified. Inserting a key that
+// already exists fails with a RevisionConflictError.
+func (s *Collection) Insert(doc Document) (string, error) {
+	pk := s.cfg.PrimaryKey
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped {
+		pkgLogger.Error("[Collection Insert] Error: collection was deleted")
+		return "", ErrCollectionNotFound
+	}
+	_, hasKey := doc.Fields[pk]
+	sequence := s.keySequence
+	if !hasKey && s.cfg.KeyStrategy != KeyStrategyNone {
+		key, err := s.generateKeyLocked()
+		if err != nil {
+			pkgLogger.Error("[Collection Insert] Error: failed to generate key", slog.Any("error", err))
+			return "", err
+		}
+		doc.Fields = maps.Clone(doc.Fields)
+		if doc.Fields == nil {
+			doc.Fields = make(map[string]DocumentField)
+		}
+		doc.Fields[pk] = DocumentField{Type: DocumentFieldTypeString, Value: key}
+	}
+	keyValue, err := s.documentKey(doc)
+	if err != nil {
+		s.keySequence = sequence
+		return "", err
+	}
+	if err := s.checkRevisionLocked(keyValue, 0); err != nil {
+		s.keySequence = sequence
+		pkgLogger.Warn("[Collection Insert] document already exists", slog.String(pk, keyValue))
+		return "", err
+	}
+	if err := s.putLocked(keyValue, &doc); err != nil {
+		s.keySequence = sequence
+		pkgLogger.Error("[Collection Insert] Error: document rejected", slog.String(pk, keyValue), slog.Any("error", err))
+		return "", err
+	}
+	pkgLogger.Info(fmt.Sprintf("[Collection Insert] Document with %s='%s' added", pk, keyValue))
+	return keyValue, nil
+}
+
+// generateKeyLocked returns a new primary key. Sequence keys already taken
+// by documents put with explicit keys are skipped.
+// Must be called with the write lock held.
+func (s *Collection) generateKeyLocked() (string, error) {
+	switch s.cfg.KeyStrategy {
+	case KeyStrategyUUIDv4:
+		return newUUIDv4()
+	case KeyStrategyUUIDv7:
+		return newUUIDv7(time.Now())
+	case KeyStrategyULID:
+		return newULID(time.Now())
+	case KeyStrategySequence:
+		for {
+			s.keySequence++
+			key := strconv.FormatUint(s.keySequence, 10)
+			if _, taken := s.documents[key]; !taken {
+				return key, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownKeyStrategy, s.cfg.KeyStrategy)
+}
+
+func newUUIDv4() (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", err
+	}
+	return formatUUID(u, 4), nil
+}
+
+func newUUIDv7(now time.Time) (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return "", err
+	}
+	putMillis(u[:6], now)
+	return formatUUID(u, 7), nil
+}
+
+// formatUUID sets the version and the RFC 9562 variant bits of u and formats it.
+func formatUUID(u [16]byte, version byte) string {
+	u[6] = u[6]&0x0f | version<<4
+	u[8] = u[8]&0x3f | 0x80
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+func newULID(now time.Time) (string, error) {
+	var u [16]byte
+	if _, err := rand.Read(u[6:]); err != nil {
+		return "", err
+	}
+	putMillis(u[:6], now)
+	// 128 bits are written as 26 base32 digits, the first one holds 3 bits.
+	hi, lo := binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+	var buf [26]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:]), nil
+}
+
+// putMillis writes the Unix time in milliseconds as a 48-bit big-endian number.
+func putMillis(dst []byte, now time.Time) {
+	ms := uint64(now.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		dst[i] = byte(ms)
+		ms >>= 8
+	}
+}
@@ -0,0 +1,149 @@
+package documentstore
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nameDoc(name string) Document {
+	return Document{Fields: map[string]DocumentField{
+		"name": {Type: DocumentFieldTypeString, Value: name},
+	}}
+}
+
+func TestKeyGenerators(t *testing.T) {
+	now := time.UnixMilli(0x0190_1234_5678)
+
+	v4, err := newUUIDv4()
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, v4)
+
+	v7, err := newUUIDv7(now)
+	require.NoError(t, err)
+	assert.Regexp(t, `^01901234-5678-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, v7)
+
+	ulid, err := newULID(now)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, ulid)
+	assert.Equal(t, "01J0938NKR", ulid[:10], "timestamp part")
+
+	later, err := newULID(now.Add(time.Millisecond))
+	require.NoError(t, err)
+	assert.Less(t, ulid, later)
+}
+
+func TestCollection_Insert(t *testing.T) {
+	patterns := map[KeyStrategy]string{
+		KeyStrategyUUIDv4:   `^[0-9a-f-]{36}$`,
+		KeyStrategyUUIDv7:   `^[0-9a-f-]{36}$`,
+		KeyStrategyULID:     `^[0-9A-Z]{26}$`,
+		KeyStrategySequence: `^[0-9]+$`,
+	}
+	for strategy, pattern := range patterns {
+		t.Run(string(strategy), func(t *testing.T) {
+			users, err := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id", KeyStrategy: strategy})
+			require.NoError(t, err)
+			doc := nameDoc("Ann")
+			key, err := users.Insert(doc)
+			require.NoError(t, err)
+			assert.Regexp(t, regexp.MustCompile(pattern), key)
+			assert.NotContains(t, doc.Fields, "id", "the caller's document is not modified")
+
+			stored, err := users.Get(key)
+			require.NoError(t, err)
+			assert.Equal(t, key, stored.Fields["id"].Value)
+
+			other, err := users.Insert(nameDoc("Bob"))
+			require.NoError(t, err)
+			assert.NotEqual(t, key, other)
+		})
+	}
+
+	t.Run("explicit keys", func(t *testing.T) {
+		users, err := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id", KeyStrategy: KeyStrategySequence})
+		require.NoError(t, err)
+		require.NoError(t, users.Put(userFields("2", nil)))
+
+		key, err := users.Insert(userFields("u1", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "u1", key)
+		_, err = users.Insert(userFields("u1", nil))
+		assert.ErrorIs(t, err, ErrRevisionConflict)
+
+		keys := make([]string, 2)
+		for i := range keys {
+			keys[i], err = users.Insert(nameDoc("n"))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{"1", "3"}, keys, "taken sequence keys are skipped")
+	})
+
+	t.Run("without strategy", func(t *testing.T) {
+		users, err := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+		require.NoError(t, err)
+		_, err = users.Insert(nameDoc("Ann"))
+		assert.ErrorIs(t, err, ErrKeyMissing)
+	})
+
+	t.Run("rejected documents do not use up the sequence", func(t *testing.T) {
+		schema := &Schema{Fields: map[string]*FieldSchema{"name": {Type: DocumentFieldTypeString, Required: true}}}
+		users, err := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id", KeyStrategy: KeyStrategySequence, Schema: schema})
+		require.NoError(t, err)
+		_, err = users.Insert(Document{Fields: map[string]DocumentField{}})
+		require.ErrorIs(t, err, ErrSchemaViolation)
+		key, err := users.Insert(nameDoc("Ann"))
+		require.NoError(t, err)
+		assert.Equal(t, "1", key)
+	})
+
+	_, err := NewStore().CreateCollection("users", &CollectionConfig{PrimaryKey: "id", KeyStrategy: "snowflake"})
+	assert.ErrorIs(t, err, ErrUnknownKeyStrategy)
+}
+
+func TestDump_KeepsKeySequence(t *testing.T) {
+	s := NewStore()
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", KeyStrategy: KeyStrategySequence})
+	require.NoError(t, err)
+	for range 3 {
+		_, err := users.Insert(nameDoc("n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, users.Delete("3"))
+
+	dump, err := s.Dump()
+	require.NoError(t, err)
+	restored, err := NewStoreFromDump(dump)
+	require.NoError(t, err)
+	users, err = restored.GetCollection("users")
+	require.NoError(t, err)
+	key, err := users.Insert(nameDoc("n"))
+	require.NoError(t, err)
+	assert.Equal(t, "4", key, "deleted keys are not reused")
+}
+
+func TestOpenStore_ReplaysKeySequence(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+	s, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", KeyStrategy: KeyStrategySequence})
+	require.NoError(t, err)
+	for range 2 {
+		_, err := users.Insert(nameDoc("n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, users.Delete("2"))
+	require.NoError(t, s.Close())
+
+	reopened, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	defer reopened.Close()
+	users, err = reopened.GetCollection("users")
+	require.NoError(t, err)
+	key, err := users.Insert(nameDoc("n"))
+	require.NoError(t, err)
+	assert.Equal(t, "3", key)
+}
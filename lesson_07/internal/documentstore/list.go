@@ -0,0 +1,240 @@
+package documentstore
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidListOptions = errors.New("[Collection] Error: invalid list options")
+	ErrInvalidCursor      = errors.New("[Collection] Error: invalid cursor")
+)
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+// SortKey orders documents by a field. Documents without the field come first
+// in ascending order; fields of different types are ordered by type name,
+// decimals are ordered together with numbers.
+type SortKey struct {
+	Field string
+	Order SortOrder
+}
+
+// ListOptions controls filtering, ordering and paging of ListWithOptions.
+// The primary key is always used as the last sort key, so the order is total.
+type ListOptions struct {
+	Filter *Filter
+	Sort   []SortKey
+	Limit  int
+	Offset int
+	// Cursor continues a previous listing, see ListResult.NextCursor.
+	// It must be used with the same Sort.
+	Cursor string
+}
+
+type ListResult struct {
+	Documents []Document
+	// NextCursor is empty when there are no more documents.
+	NextCursor string
+}
+
+// listCursor points right after the last returned document.
+// It stores sort values instead of a position, so pages do not shift
+// when documents are inserted or deleted in between.
+type listCursor struct {
+	Sort   string           `json:"s"`
+	Values []*DocumentField `json:"v"`
+	Key    string           `json:"k"`
+}
+
+type sortItem struct {
+	key    string
+	doc    Document
+	values []*DocumentField
+}
+
+func (o ListOptions) validate() error {
+	if o.Limit < 0 || o.Offset < 0 {
+		return fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidListOptions)
+	}
+	for _, sk := range o.Sort {
+		if strings.TrimSpace(sk.Field) == "" {
+			return fmt.Errorf("%w: sort field is empty", ErrInvalidListOptions)
+		}
+		if sk.Order != "" && sk.Order != SortAsc && sk.Order != SortDesc {
+			return fmt.Errorf("%w: unknown sort order %q", ErrInvalidListOptions, sk.Order)
+		}
+	}
+	if o.Filter != nil {
+		return o.Filter.Validate()
+	}
+	return nil
+}
+
+// sortSignature identifies the sort spec a cursor was created for.
+func sortSignature(keys []SortKey) string {
+	var b strings.Builder
+	for _, sk := range keys {
+		order := sk.Order
+		if order == "" {
+			order = SortAsc
+		}
+		b.WriteString(sk.Field + ":" + string(order) + ";")
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+func encodeCursor(c listCursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c listCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return &c, nil
+}
+
+func sortValues(doc *Document, keys []SortKey) []*DocumentField {
+	values := make([]*DocumentField, len(keys))
+	for i, sk := range keys {
+		if field, ok := lookupField(doc, sk.Field); ok {
+			values[i] = &field
+		}
+	}
+	return values
+}
+
+func compareSortValue(a, b *DocumentField) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case orderType(a.Type) != orderType(b.Type):
+		return strings.Compare(string(orderType(a.Type)), string(orderType(b.Type)))
+	}
+	c, _ := compareFields(*a, *b)
+	return c
+}
+
+// compareSortItems compares two positions in the order defined by keys.
+func compareSortItems(keys []SortKey, aValues []*DocumentField, aKey string, bValues []*DocumentField, bKey string) int {
+	for i, sk := range keys {
+		c := compareSortValue(aValues[i], bValues[i])
+		if sk.Order == SortDesc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(aKey, bKey)
+}
+
+// ListWithOptions returns a page of documents in a stable order.
+func (s *Collection) ListWithOptions(opts ListOptions) (*ListResult, error) {
+	if err := opts.validate(); err != nil {
+		pkgLogger.Error("[Collection ListWithOptions] invalid options", slog.Any("error", err))
+		return nil, err
+	}
+	signature := sortSignature(opts.Sort)
+	var after *listCursor
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			pkgLogger.Error("[Collection ListWithOptions] invalid cursor", slog.Any("error", err))
+			return nil, err
+		}
+		if c.Sort != signature || len(c.Values) != len(opts.Sort) {
+			pkgLogger.Error("[Collection ListWithOptions] cursor does not match sort options")
+			return nil, fmt.Errorf("%w: cursor was created for different sort options", ErrInvalidCursor)
+		}
+		after = c
+	}
+
+	items := s.collectSortItems(opts)
+	sort.Slice(items, func(i, j int) bool {
+		return compareSortItems(opts.Sort, items[i].values, items[i].key, items[j].values, items[j].key) < 0
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return compareSortItems(opts.Sort, items[i].values, items[i].key, after.Values, after.Key) > 0
+		})
+	}
+	start = min(start+opts.Offset, len(items))
+	end := len(items)
+	if opts.Limit > 0 {
+		end = min(start+opts.Limit, len(items))
+	}
+
+	result := &ListResult{Documents: make([]Document, 0, end-start)}
+	for _, item := range items[start:end] {
+		result.Documents = append(result.Documents, item.doc)
+	}
+	if end < len(items) && end > start {
+		last := items[end-1]
+		cursor, err := encodeCursor(listCursor{Sort: signature, Values: last.values, Key: last.key})
+		if err != nil {
+			pkgLogger.Error("[Collection ListWithOptions] failed to encode cursor", slog.Any("error", err))
+			return nil, err
+		}
+		result.NextCursor = cursor
+	}
+	pkgLogger.Info("[Collection ListWithOptions] documents listed", slog.Int("returned", len(result.Documents)), slog.Int("total", len(items)))
+	return result, nil
+}
+
+// collectSortItems copies matching documents together with their sort values
+// under the read lock, so sorting happens without holding it.
+func (s *Collection) collectSortItems(opts ListOptions) []sortItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := make([]sortItem, 0, len(s.documents))
+	add := func(key string, doc *Document) {
+		if opts.Filter != nil && !opts.Filter.Match(doc) {
+			return
+		}
+		items = append(items, sortItem{key: key, doc: *doc, values: sortValues(doc, opts.Sort)})
+	}
+	if opts.Filter != nil {
+		if keys, ok := s.candidates(*opts.Filter); ok {
+			for key := range keys {
+				if doc, found := s.documents[key]; found {
+					add(key, doc)
+				}
+			}
+			return items
+		}
+	}
+	for key, doc := range s.documents {
+		add(key, doc)
+	}
+	return items
+}
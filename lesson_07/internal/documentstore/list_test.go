@@ -0,0 +1,107 @@
+package documentstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList_OrderedByPrimaryKey(t *testing.T) {
+	col := NewCollection(nil)
+	for _, id := range []string{"c", "a", "d", "b"} {
+		require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+			"id": {Type: DocumentFieldTypeString, Value: id},
+		}}))
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids(col.List()))
+}
+
+func TestListWithOptions_Sort(t *testing.T) {
+	col := newUsersCollection(t)
+	// same age as u2 to check the tie-break, and one without age
+	require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+		"id":     {Type: DocumentFieldTypeString, Value: "u0"},
+		"age":    {Type: DocumentFieldTypeNumber, Value: 25.0},
+		"status": {Type: DocumentFieldTypeString, Value: "blocked"},
+	}}))
+	require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: "u9"},
+	}}))
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{"default by key", ListOptions{}, []string{"u0", "u1", "u2", "u3", "u4", "u9"}},
+		{"age asc", ListOptions{Sort: []SortKey{{Field: "age"}}}, []string{"u9", "u1", "u0", "u2", "u3", "u4"}},
+		{"age desc", ListOptions{Sort: []SortKey{{Field: "age", Order: SortDesc}}}, []string{"u4", "u3", "u0", "u2", "u1", "u9"}},
+		{
+			"status asc then age desc",
+			ListOptions{Sort: []SortKey{{Field: "status"}, {Field: "age", Order: SortDesc}}},
+			[]string{"u9", "u4", "u2", "u1", "u3", "u0"},
+		},
+		{"filter limit offset", ListOptions{Filter: &Filter{Op: FilterOpEq, Field: "status", Value: "active"}, Limit: 2, Offset: 1}, []string{"u2", "u4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := col.ListWithOptions(tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(res.Documents))
+		})
+	}
+}
+
+func TestListWithOptions_Cursor(t *testing.T) {
+	col := NewCollection(nil)
+	put := func(id string, score int) {
+		require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+			"id":    {Type: DocumentFieldTypeString, Value: id},
+			"score": {Type: DocumentFieldTypeNumber, Value: score},
+		}}))
+	}
+	for i := 0; i < 5; i++ {
+		put(fmt.Sprintf("d%d", i), i*10)
+	}
+	opts := ListOptions{Sort: []SortKey{{Field: "score", Order: SortDesc}}, Limit: 2}
+
+	page1, err := col.ListWithOptions(opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d4", "d3"}, ids(page1.Documents))
+	require.NotEmpty(t, page1.NextCursor)
+
+	// inserts before and after the cursor position do not shift the next page
+	put("top", 100)
+	put("mid", 15)
+
+	opts.Cursor = page1.NextCursor
+	page2, err := col.ListWithOptions(opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d2", "mid"}, ids(page2.Documents))
+
+	opts.Cursor = page2.NextCursor
+	page3, err := col.ListWithOptions(opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d1", "d0"}, ids(page3.Documents))
+	assert.Empty(t, page3.NextCursor)
+}
+
+func TestListWithOptions_Errors(t *testing.T) {
+	col := newUsersCollection(t)
+
+	_, err := col.ListWithOptions(ListOptions{Limit: -1})
+	assert.ErrorIs(t, err, ErrInvalidListOptions)
+	_, err = col.ListWithOptions(ListOptions{Sort: []SortKey{{Field: ""}}})
+	assert.ErrorIs(t, err, ErrInvalidListOptions)
+	_, err = col.ListWithOptions(ListOptions{Sort: []SortKey{{Field: "age", Order: "up"}}})
+	assert.ErrorIs(t, err, ErrInvalidListOptions)
+	_, err = col.ListWithOptions(ListOptions{Cursor: "%%%"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	res, err := col.ListWithOptions(ListOptions{Sort: []SortKey{{Field: "age"}}, Limit: 1})
+	require.NoError(t, err)
+	_, err = col.ListWithOptions(ListOptions{Sort: []SortKey{{Field: "status"}}, Cursor: res.NextCursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
@@ -0,0 +1,201 @@
+package documentstore
+
+import (
+	"bytes"
+	"encoding"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+// DocumentMarshaler is implemented by types that convert themselves into a
+// document. A nested value implementing it is stored as an object field.
+//
+// Implementations must not call MarshalDocument on their own type, use a
+// type without methods (type plain T) instead to avoid infinite recursion.
+type DocumentMarshaler interface {
+	MarshalDocument() (*Document, error)
+}
+
+// DocumentUnmarshaler is implemented by types that fill themselves from a
+// document. A nested value implementing it receives the fields of its object.
+type DocumentUnmarshaler interface {
+	UnmarshalDocument(doc *Document) error
+}
+
+// FieldMarshaler is implemented by types stored as a single field,
+// for example an enum stored as a string or money stored as a number of cents.
+type FieldMarshaler interface {
+	MarshalDocumentField() (DocumentField, error)
+}
+
+// FieldUnmarshaler is the counterpart of FieldMarshaler.
+type FieldUnmarshaler interface {
+	UnmarshalDocumentField(field DocumentField) error
+}
+
+var (
+	documentMarshalerType   = reflect.TypeFor[DocumentMarshaler]()
+	documentUnmarshalerType = reflect.TypeFor[DocumentUnmarshaler]()
+	fieldMarshalerType      = reflect.TypeFor[FieldMarshaler]()
+	fieldUnmarshalerType    = reflect.TypeFor[FieldUnmarshaler]()
+	textMarshalerType       = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType     = reflect.TypeFor[encoding.TextUnmarshaler]()
+	timeType                = reflect.TypeFor[time.Time]()
+	durationType            = reflect.TypeFor[time.Duration]()
+)
+
+// implementer returns v as iface, using its address for pointer receivers.
+// Values that are not addressable are copied.
+func implementer(v reflect.Value, iface reflect.Type) (any, bool) {
+	switch {
+	case v.Type().Implements(iface):
+		return v.Interface(), true
+	case v.CanAddr() && v.Addr().Type().Implements(iface):
+		return v.Addr().Interface(), true
+	case reflect.PointerTo(v.Type()).Implements(iface):
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		return ptr.Interface(), true
+	}
+	return nil, false
+}
+
+// marshalCustom converts values of types with their own representation:
+// FieldMarshaler, DocumentMarshaler, time.Time (timestamp), big.Rat (decimal),
+// encoding.TextMarshaler (string) and []byte (binary). ok is false for all
+// other values. time.Duration needs nothing special, it is stored as a number
+// of nanoseconds.
+func marshalCustom(v reflect.Value) (field DocumentField, ok bool, err error) {
+	if m, ok := implementer(v, fieldMarshalerType); ok {
+		field, err := m.(FieldMarshaler).MarshalDocumentField()
+		return field, true, err
+	}
+	if m, ok := implementer(v, documentMarshalerType); ok {
+		doc, err := m.(DocumentMarshaler).MarshalDocument()
+		if err != nil || doc == nil {
+			return DocumentField{Type: DocumentFieldTypeObject}, true, err
+		}
+		return DocumentField{Type: DocumentFieldTypeObject, Value: doc.Fields}, true, nil
+	}
+	switch v.Type() {
+	case timeType:
+		return DocumentField{Type: DocumentFieldTypeTimestamp, Value: v.Interface().(time.Time)}, true, nil
+	case ratType:
+		r, _ := toRat(v.Interface())
+		return DocumentField{Type: DocumentFieldTypeDecimal, Value: r}, true, nil
+	}
+	if m, ok := implementer(v, textMarshalerType); ok {
+		text, err := m.(encoding.TextMarshaler).MarshalText()
+		return DocumentField{Type: DocumentFieldTypeString, Value: string(text)}, true, err
+	}
+	if isBytesType(v.Type()) {
+		if v.IsNil() {
+			return DocumentField{Type: DocumentFieldTypeBinary}, true, nil
+		}
+		return DocumentField{Type: DocumentFieldTypeBinary, Value: bytes.Clone(v.Bytes())}, true, nil
+	}
+	return DocumentField{}, false, nil
+}
+
+// decodeCustom is the decoding counterpart of marshalCustom. handled is false
+// when typ has no custom decoding for the stored value.
+func (d *decoder) decodeCustom(path string, v any, typ reflect.Type) (out reflect.Value, handled bool) {
+	field, ok := v.(DocumentField)
+	if !ok {
+		field, _ = fieldFromValue(v)
+	}
+	ptrType := reflect.PointerTo(typ)
+	invalid := func(err error) (reflect.Value, bool) {
+		d.fail(path, FieldErrorInvalid, typ.String(), err.Error())
+		return reflect.Value{}, true
+	}
+	switch {
+	case ptrType.Implements(fieldUnmarshalerType):
+		ptr := reflect.New(typ)
+		if err := ptr.Interface().(FieldUnmarshaler).UnmarshalDocumentField(field); err != nil {
+			return invalid(err)
+		}
+		return ptr.Elem(), true
+	case ptrType.Implements(documentUnmarshalerType):
+		fields, ok := objectFields(field)
+		if !ok {
+			d.fail(path, FieldErrorTypeMismatch, typ.String(), storedTypeName(v))
+			return reflect.Value{}, true
+		}
+		ptr := reflect.New(typ)
+		if err := ptr.Interface().(DocumentUnmarshaler).UnmarshalDocument(&Document{Fields: fields}); err != nil {
+			return invalid(err)
+		}
+		return ptr.Elem(), true
+	case typ == timeType:
+		switch value := field.Value.(type) {
+		case time.Time:
+			return reflect.ValueOf(value), true
+		case string:
+			// timestamps were stored as RFC3339 strings before the timestamp type
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return invalid(err)
+			}
+			return reflect.ValueOf(t), true
+		}
+		return reflect.Value{}, false
+	case typ == ratType:
+		if value, ok := field.Value.(string); ok {
+			r, err := parseDecimal(value)
+			if err != nil {
+				return invalid(err)
+			}
+			return reflect.ValueOf(r).Elem(), true
+		}
+		r, ok := toRat(field.Value)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(new(big.Rat).Set(r)).Elem(), true
+	case field.Type == DocumentFieldTypeDecimal && !d.opts.strictTypes &&
+		(typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64):
+		r, ok := toRat(field.Value)
+		if !ok {
+			return reflect.Value{}, false
+		}
+		f, _ := r.Float64()
+		return reflect.ValueOf(f).Convert(typ), true
+	}
+	s, isString := field.Value.(string)
+	switch {
+	case !isString:
+		return reflect.Value{}, false
+	case typ == durationType:
+		dur, err := time.ParseDuration(s)
+		if err != nil {
+			return invalid(err)
+		}
+		return reflect.ValueOf(dur), true
+	case ptrType.Implements(textUnmarshalerType):
+		ptr := reflect.New(typ)
+		if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return invalid(err)
+		}
+		return ptr.Elem(), true
+	}
+	return reflect.Value{}, false
+}
+
+// objectFields returns the fields of an object stored either as a
+// DocumentField tree or as a plain Go value.
+func objectFields(f DocumentField) (map[string]DocumentField, bool) {
+	if fields, ok := f.Value.(map[string]DocumentField); ok {
+		return fields, true
+	}
+	if f.Value == nil {
+		return nil, false
+	}
+	tree, err := marshalField(reflect.ValueOf(f.Value))
+	if err != nil || tree.Type != DocumentFieldTypeObject {
+		return nil, false
+	}
+	fields, ok := tree.Value.(map[string]DocumentField)
+	return fields, ok
+}
@@ -0,0 +1,167 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type status int
+
+const (
+	statusActive status = iota + 1
+	statusBlocked
+)
+
+var statusNames = map[status]string{statusActive: "active", statusBlocked: "blocked"}
+
+func (s status) MarshalDocumentField() (DocumentField, error) {
+	name, ok := statusNames[s]
+	if !ok {
+		return DocumentField{}, fmt.Errorf("unknown status %d", s)
+	}
+	return DocumentField{Type: DocumentFieldTypeString, Value: name}, nil
+}
+
+func (s *status) UnmarshalDocumentField(f DocumentField) error {
+	for value, name := range statusNames {
+		if f.Value == name {
+			*s = value
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown status %v", f.Value)
+}
+
+// money is stored as an object of units and cents.
+type money struct {
+	cents int64
+}
+
+func (m money) MarshalDocument() (*Document, error) {
+	return &Document{Fields: map[string]DocumentField{
+		"units": {Type: DocumentFieldTypeNumber, Value: m.cents / 100},
+		"cents": {Type: DocumentFieldTypeNumber, Value: m.cents % 100},
+	}}, nil
+}
+
+func (m *money) UnmarshalDocument(doc *Document) error {
+	var parts struct {
+		Units int64 `doc:"units"`
+		Cents int64 `doc:"cents"`
+	}
+	if err := UnmarshalDocument(doc, &parts, StrictTypes()); err != nil {
+		return err
+	}
+	m.cents = parts.Units*100 + parts.Cents
+	return nil
+}
+
+type order struct {
+	ID        string        `doc:"id"`
+	Status    status        `doc:"status"`
+	Total     money         `doc:"total"`
+	CreatedAt time.Time     `doc:"createdAt"`
+	PaidAt    *time.Time    `doc:"paidAt"`
+	Timeout   time.Duration `doc:"timeout"`
+	ClientIP  net.IP        `doc:"clientIP"`
+	Discount  *money        `doc:"discount"`
+	Note      *string       `doc:"note"`
+}
+
+func TestMarshalDocument_CustomTypes(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 30, 0, 123456789, time.UTC)
+	note := "gift"
+	o := order{
+		ID:        "o1",
+		Status:    statusBlocked,
+		Total:     money{cents: 1999},
+		CreatedAt: created,
+		Timeout:   90 * time.Second,
+		ClientIP:  net.ParseIP("10.0.0.1"),
+		Note:      &note,
+	}
+	doc, err := MarshalDocument(&o)
+	require.NoError(t, err)
+
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeString, Value: "blocked"}, doc.Fields["status"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeTimestamp, Value: created}, doc.Fields["createdAt"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeString, Value: "10.0.0.1"}, doc.Fields["clientIP"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeString, Value: "gift"}, doc.Fields["note"])
+	assert.Equal(t, DocumentFieldTypeNumber, doc.Fields["timeout"].Type)
+	total := doc.Fields["total"].Value.(map[string]DocumentField)
+	assert.Equal(t, int64(19), total["units"].Value)
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeNull}, doc.Fields["paidAt"])
+
+	s := NewStore()
+	orders, err := s.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, orders.Put(*doc))
+	dump, err := s.Dump()
+	require.NoError(t, err)
+	restored, err := NewStoreFromDump(dump)
+	require.NoError(t, err)
+	orders, _ = restored.GetCollection("orders")
+	loaded, err := orders.Get("o1")
+	require.NoError(t, err)
+
+	var out order
+	require.NoError(t, UnmarshalDocument(loaded, &out, Strict()))
+	assert.True(t, out.CreatedAt.Equal(created))
+	out.CreatedAt = created
+	assert.Equal(t, o, out)
+
+	found, err := orders.Find(Eq("createdAt", created))
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	t.Run("marshal error", func(t *testing.T) {
+		_, err := MarshalDocument(order{ID: "o2", Status: 42})
+		assert.ErrorContains(t, err, "unknown status 42")
+	})
+}
+
+func TestUnmarshalDocument_CustomTypes(t *testing.T) {
+	doc := &Document{Fields: map[string]DocumentField{
+		"id":        {Type: DocumentFieldTypeString, Value: "o1"},
+		"status":    {Type: DocumentFieldTypeString, Value: "archived"},
+		"createdAt": {Type: DocumentFieldTypeString, Value: "yesterday"},
+		"timeout":   {Type: DocumentFieldTypeString, Value: "1m30s"},
+		"total":     {Type: DocumentFieldTypeString, Value: "19.99"},
+	}}
+
+	var lenient order
+	require.NoError(t, UnmarshalDocument(doc, &lenient))
+	assert.Equal(t, "o1", lenient.ID)
+	assert.Equal(t, 90*time.Second, lenient.Timeout)
+	assert.Zero(t, lenient.Status)
+
+	var strict order
+	err := UnmarshalDocument(doc, &strict, StrictTypes())
+	var uerr *UnmarshalError
+	require.True(t, errors.As(err, &uerr))
+	kinds := make(map[string]FieldErrorKind)
+	for _, f := range uerr.Fields {
+		kinds[f.Path] = f.Kind
+	}
+	assert.Equal(t, map[string]FieldErrorKind{
+		"status":    FieldErrorInvalid,
+		"createdAt": FieldErrorInvalid,
+		"total":     FieldErrorTypeMismatch,
+	}, kinds)
+}
+
+func TestMarshalDocument_DocumentMarshaler(t *testing.T) {
+	doc, err := MarshalDocument(money{cents: 250})
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(doc.Fields))
+
+	var m money
+	require.NoError(t, UnmarshalDocument(doc, &m))
+	assert.Equal(t, int64(250), m.cents)
+}
@@ -0,0 +1,282 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidPath = errors.New("[Document] Error: invalid field path")
+)
+
+// Field paths address nested fields: names of object fields are separated by
+// dots and array elements are selected by index, e.g. "address.city",
+// "roles[0]" or "orders[1].items[0].sku". A top-level field whose name is
+// exactly the path takes precedence, so existing names with dots keep working.
+//
+// Paths are accepted wherever a field name is: in filters, update operators,
+// index and unique constraint configs and sort keys.
+
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parsePath(path string) ([]pathStep, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("%w: path is empty", ErrInvalidPath)
+	}
+	var steps []pathStep
+	for _, part := range strings.Split(path, ".") {
+		name, indexes := part, ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			name, indexes = part[:i], part[i:]
+		}
+		if name == "" || strings.ContainsRune(name, ']') {
+			return nil, fmt.Errorf("%w: %q: bad field name %q", ErrInvalidPath, path, name)
+		}
+		steps = append(steps, pathStep{key: name})
+		for indexes != "" {
+			end := strings.IndexByte(indexes, ']')
+			if indexes[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("%w: %q: bad index %q", ErrInvalidPath, path, indexes)
+			}
+			n, err := strconv.Atoi(indexes[1:end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: %q: bad index %q", ErrInvalidPath, path, indexes[:end+1])
+			}
+			steps = append(steps, pathStep{index: n, isIndex: true})
+			indexes = indexes[end+1:]
+		}
+	}
+	return steps, nil
+}
+
+// GetPath returns the field addressed by path.
+func (d *Document) GetPath(path string) (DocumentField, bool) {
+	if d == nil || d.Fields == nil {
+		return DocumentField{}, false
+	}
+	if f, ok := d.Fields[path]; ok {
+		return f, true
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return DocumentField{}, false
+	}
+	f, ok := d.Fields[steps[0].key]
+	for _, step := range steps[1:] {
+		if !ok {
+			break
+		}
+		f, ok = childField(f, step)
+	}
+	return f, ok
+}
+
+// SetPath stores value at path. Missing objects on the way are created, an
+// array index may address an existing element or the one right after the
+// last. Objects and arrays on the way are copied, not modified in place.
+func (d *Document) SetPath(path string, value any) error {
+	field, ok := fieldFromValue(value)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrUnsupportedDocumentField, value)
+	}
+	if d.Fields == nil {
+		d.Fields = make(map[string]DocumentField)
+	}
+	if _, ok := d.Fields[path]; ok {
+		d.Fields[path] = field
+		return nil
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	root := steps[0].key
+	cur, exists := d.Fields[root]
+	updated, err := setChild(root, cur, exists, steps[1:], field)
+	if err != nil {
+		return err
+	}
+	d.Fields[root] = updated
+	return nil
+}
+
+// DeletePath removes the field at path. Array elements after a removed one
+// move down. Deleting a missing field is not an error.
+func (d *Document) DeletePath(path string) error {
+	if d == nil || d.Fields == nil {
+		return nil
+	}
+	if _, ok := d.Fields[path]; ok {
+		delete(d.Fields, path)
+		return nil
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	root := steps[0].key
+	if len(steps) == 1 {
+		delete(d.Fields, root)
+		return nil
+	}
+	if cur, ok := d.Fields[root]; ok {
+		if updated, changed := deleteChild(cur, steps[1:]); changed {
+			d.Fields[root] = updated
+		}
+	}
+	return nil
+}
+
+func childField(f DocumentField, step pathStep) (DocumentField, bool) {
+	if step.isIndex {
+		if f.Type != DocumentFieldTypeArray {
+			return DocumentField{}, false
+		}
+		arr := reflect.ValueOf(f.Value)
+		if (arr.Kind() != reflect.Slice && arr.Kind() != reflect.Array) || step.index >= arr.Len() {
+			return DocumentField{}, false
+		}
+		return fieldFromValue(arr.Index(step.index).Interface())
+	}
+	if f.Type != DocumentFieldTypeObject {
+		return DocumentField{}, false
+	}
+	fields, ok := objectFields(f)
+	if !ok {
+		return DocumentField{}, false
+	}
+	child, ok := fields[step.key]
+	return child, ok
+}
+
+// arrayFields returns a copy of the elements of an array field.
+func arrayFields(f DocumentField) ([]DocumentField, bool) {
+	if elems, ok := f.Value.([]DocumentField); ok {
+		return slices.Clone(elems), true
+	}
+	arr := reflect.ValueOf(f.Value)
+	if !arr.IsValid() {
+		return nil, true
+	}
+	if arr.Kind() != reflect.Slice && arr.Kind() != reflect.Array {
+		return nil, false
+	}
+	elems := make([]DocumentField, arr.Len())
+	for i := range elems {
+		elem, ok := fieldFromValue(arr.Index(i).Interface())
+		if !ok {
+			return nil, false
+		}
+		elems[i] = elem
+	}
+	return elems, true
+}
+
+// setChild returns f with value stored at steps below it, path names f in errors.
+func setChild(path string, f DocumentField, exists bool, steps []pathStep, value DocumentField) (DocumentField, error) {
+	if len(steps) == 0 {
+		return value, nil
+	}
+	step := steps[0]
+	if step.isIndex {
+		childPath := fmt.Sprintf("%s[%d]", path, step.index)
+		if !exists || f.Type != DocumentFieldTypeArray {
+			return DocumentField{}, fmt.Errorf("%w: %s is not an array", ErrInvalidPath, path)
+		}
+		elems, ok := arrayFields(f)
+		if !ok {
+			return DocumentField{}, fmt.Errorf("%w: %s holds unsupported elements", ErrInvalidPath, path)
+		}
+		if step.index > len(elems) {
+			return DocumentField{}, fmt.Errorf("%w: %s is out of range", ErrInvalidPath, childPath)
+		}
+		var child DocumentField
+		childExists := step.index < len(elems)
+		if childExists {
+			child = elems[step.index]
+		}
+		updated, err := setChild(childPath, child, childExists, steps[1:], value)
+		if err != nil {
+			return DocumentField{}, err
+		}
+		if childExists {
+			elems[step.index] = updated
+		} else {
+			elems = append(elems, updated)
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: elems}, nil
+	}
+	var fields map[string]DocumentField
+	switch {
+	case !exists || f.Type == DocumentFieldTypeNull:
+		fields = make(map[string]DocumentField)
+	case f.Type == DocumentFieldTypeObject:
+		src, _ := objectFields(f)
+		fields = maps.Clone(src)
+		if fields == nil {
+			fields = make(map[string]DocumentField)
+		}
+	default:
+		return DocumentField{}, fmt.Errorf("%w: %s is %s, not an object", ErrInvalidPath, path, f.Type)
+	}
+	child, childExists := fields[step.key]
+	updated, err := setChild(path+"."+step.key, child, childExists, steps[1:], value)
+	if err != nil {
+		return DocumentField{}, err
+	}
+	fields[step.key] = updated
+	return DocumentField{Type: DocumentFieldTypeObject, Value: fields}, nil
+}
+
+// deleteChild returns f without the field at steps, changed is false when
+// there was nothing to delete.
+func deleteChild(f DocumentField, steps []pathStep) (DocumentField, bool) {
+	step, last := steps[0], len(steps) == 1
+	if step.isIndex {
+		if f.Type != DocumentFieldTypeArray {
+			return f, false
+		}
+		elems, ok := arrayFields(f)
+		if !ok || step.index >= len(elems) {
+			return f, false
+		}
+		if last {
+			elems = slices.Delete(elems, step.index, step.index+1)
+		} else {
+			child, changed := deleteChild(elems[step.index], steps[1:])
+			if !changed {
+				return f, false
+			}
+			elems[step.index] = child
+		}
+		return DocumentField{Type: DocumentFieldTypeArray, Value: elems}, true
+	}
+	if f.Type != DocumentFieldTypeObject {
+		return f, false
+	}
+	src, ok := objectFields(f)
+	child, found := src[step.key]
+	if !ok || !found {
+		return f, false
+	}
+	fields := maps.Clone(src)
+	if last {
+		delete(fields, step.key)
+	} else {
+		updated, changed := deleteChild(child, steps[1:])
+		if !changed {
+			return f, false
+		}
+		fields[step.key] = updated
+	}
+	return DocumentField{Type: DocumentFieldTypeObject, Value: fields}, true
+}
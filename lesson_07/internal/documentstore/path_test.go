@@ -0,0 +1,169 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nestedDoc(id, city string, zip int, roles ...string) Document {
+	roleFields := make([]DocumentField, len(roles))
+	for i, r := range roles {
+		roleFields[i] = DocumentField{Type: DocumentFieldTypeString, Value: r}
+	}
+	return Document{Fields: map[string]DocumentField{
+		"id": {Type: DocumentFieldTypeString, Value: id},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]DocumentField{
+			"city": {Type: DocumentFieldTypeString, Value: city},
+			"zip":  {Type: DocumentFieldTypeNumber, Value: zip},
+		}},
+		"roles": {Type: DocumentFieldTypeArray, Value: roleFields},
+	}}
+}
+
+func TestParsePath(t *testing.T) {
+	steps, err := parsePath("orders[1][0].items.sku")
+	require.NoError(t, err)
+	assert.Equal(t, []pathStep{
+		{key: "orders"}, {index: 1, isIndex: true}, {index: 0, isIndex: true}, {key: "items"}, {key: "sku"},
+	}, steps)
+
+	for _, bad := range []string{"", "a..b", ".a", "a.", "[0]", "a[", "a[x]", "a[-1]", "a]b", "a[0]b"} {
+		_, err := parsePath(bad)
+		assert.ErrorIs(t, err, ErrInvalidPath, bad)
+	}
+}
+
+func TestDocument_GetPath(t *testing.T) {
+	doc := nestedDoc("u1", "Kyiv", 1001, "admin", "dev")
+	doc.Fields["plain"] = DocumentField{Type: DocumentFieldTypeArray, Value: []any{
+		map[string]any{"tags": []string{"x", "y"}},
+	}}
+	doc.Fields["a.b"] = DocumentField{Type: DocumentFieldTypeBool, Value: true}
+
+	tests := []struct {
+		path string
+		want DocumentField
+		ok   bool
+	}{
+		{"address.city", DocumentField{Type: DocumentFieldTypeString, Value: "Kyiv"}, true},
+		{"roles[1]", DocumentField{Type: DocumentFieldTypeString, Value: "dev"}, true},
+		{"plain[0].tags[1]", DocumentField{Type: DocumentFieldTypeString, Value: "y"}, true},
+		{"a.b", DocumentField{Type: DocumentFieldTypeBool, Value: true}, true},
+		{"roles[2]", DocumentField{}, false},
+		{"address.street", DocumentField{}, false},
+		{"address.city.name", DocumentField{}, false},
+		{"roles.admin", DocumentField{}, false},
+		{"address[0]", DocumentField{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			f, ok := doc.GetPath(tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, f)
+		})
+	}
+}
+
+func TestDocument_SetPath(t *testing.T) {
+	original := nestedDoc("u1", "Kyiv", 1001, "admin")
+	doc := Document{Fields: map[string]DocumentField{}}
+	for k, v := range original.Fields {
+		doc.Fields[k] = v
+	}
+
+	require.NoError(t, doc.SetPath("address.city", "Lviv"))
+	require.NoError(t, doc.SetPath("roles[0]", "owner"))
+	require.NoError(t, doc.SetPath("roles[1]", "dev"))
+	require.NoError(t, doc.SetPath("profile.links.site", "example.com"))
+
+	city, _ := doc.GetPath("address.city")
+	assert.Equal(t, "Lviv", city.Value)
+	roles, _ := doc.GetPath("roles")
+	assert.Equal(t, []DocumentField{
+		{Type: DocumentFieldTypeString, Value: "owner"},
+		{Type: DocumentFieldTypeString, Value: "dev"},
+	}, roles.Value)
+	site, ok := doc.GetPath("profile.links.site")
+	assert.True(t, ok)
+	assert.Equal(t, "example.com", site.Value)
+
+	// containers on the way are copied
+	city, _ = original.GetPath("address.city")
+	assert.Equal(t, "Kyiv", city.Value)
+	role, _ := original.GetPath("roles[0]")
+	assert.Equal(t, "admin", role.Value)
+
+	assert.ErrorIs(t, doc.SetPath("roles[5]", "x"), ErrInvalidPath)
+	assert.ErrorIs(t, doc.SetPath("address.city.name", "x"), ErrInvalidPath)
+	assert.ErrorIs(t, doc.SetPath("address[0]", "x"), ErrInvalidPath)
+	assert.ErrorIs(t, doc.SetPath("a[", "x"), ErrInvalidPath)
+}
+
+func TestDocument_DeletePath(t *testing.T) {
+	doc := nestedDoc("u1", "Kyiv", 1001, "admin", "dev", "ops")
+	original := doc.Fields["roles"]
+
+	require.NoError(t, doc.DeletePath("roles[1]"))
+	require.NoError(t, doc.DeletePath("address.zip"))
+	require.NoError(t, doc.DeletePath("address.street"))
+	require.NoError(t, doc.DeletePath("roles[7]"))
+
+	roles, _ := doc.GetPath("roles")
+	assert.Equal(t, []DocumentField{
+		{Type: DocumentFieldTypeString, Value: "admin"},
+		{Type: DocumentFieldTypeString, Value: "ops"},
+	}, roles.Value)
+	_, ok := doc.GetPath("address.zip")
+	assert.False(t, ok)
+	assert.Len(t, original.Value, 3)
+	assert.ErrorIs(t, doc.DeletePath("a..b"), ErrInvalidPath)
+}
+
+func TestCollection_NestedPaths(t *testing.T) {
+	s := NewStore()
+	users, err := s.CreateCollection("users", &CollectionConfig{
+		PrimaryKey: "id",
+		Indexes: []IndexConfig{
+			{Field: "address.city", Type: IndexTypeHash},
+			{Field: "address.zip", Type: IndexTypeOrdered},
+		},
+		Unique: []UniqueConstraint{{Fields: []string{"roles[0]"}}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, users.Put(nestedDoc("u1", "Kyiv", 1001, "admin")))
+	require.NoError(t, users.Put(nestedDoc("u2", "Lviv", 79000, "dev")))
+	require.NoError(t, users.Put(nestedDoc("u3", "Kyiv", 1004, "ops", "dev")))
+
+	docs, err := users.Find(Eq("address.city", "Kyiv"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"u1", "u3"}, ids(docs))
+
+	docs, err = users.Find(And(Gt("address.zip", 1001), Eq("roles[1]", "dev")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"u3"}, ids(docs))
+
+	res, err := users.ListWithOptions(ListOptions{Sort: []SortKey{{Field: "address.zip", Order: SortDesc}}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"u2", "u3", "u1"}, ids(res.Documents))
+
+	assert.ErrorIs(t, users.Put(nestedDoc("u4", "Odesa", 65000, "admin")), ErrUniqueViolation)
+
+	updated, err := users.Update("u1", Set("address.city", "Odesa"), Push("roles", "dev"), Unset("address.zip"))
+	require.NoError(t, err)
+	city, _ := updated.GetPath("address.city")
+	assert.Equal(t, "Odesa", city.Value)
+	role, _ := updated.GetPath("roles[1]")
+	assert.Equal(t, "dev", role.Value)
+
+	docs, err = users.Find(Eq("address.city", "Kyiv"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"u3"}, ids(docs), "index follows nested updates")
+	docs, err = users.Find(Exists("address.zip", false))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"u1"}, ids(docs))
+
+	_, err = users.Update("u1", Set("address.city.name", "x"))
+	assert.ErrorIs(t, err, ErrUpdateTypeMismatch)
+}
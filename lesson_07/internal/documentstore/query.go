@@ -0,0 +1,325 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidFilter = errors.New("[Collection] Error: invalid filter")
+)
+
+type FilterOp string
+
+const (
+	FilterOpEq     FilterOp = "$eq"
+	FilterOpNe     FilterOp = "$ne"
+	FilterOpGt     FilterOp = "$gt"
+	FilterOpGte    FilterOp = "$gte"
+	FilterOpLt     FilterOp = "$lt"
+	FilterOpLte    FilterOp = "$lte"
+	FilterOpIn     FilterOp = "$in"
+	FilterOpExists FilterOp = "$exists"
+	// FilterOpContains matches arrays with an element equal to Value and
+	// strings containing the string Value.
+	FilterOpContains FilterOp = "$contains"
+	FilterOpAnd      FilterOp = "$and"
+	FilterOpOr       FilterOp = "$or"
+	FilterOpNot      FilterOp = "$not"
+)
+
+// Filter is a predicate over document fields.
+// Field operators ($eq, $gt, $in, ...) use Field and Value,
+// logical operators ($and, $or, $not) use Filters.
+// Value is either a DocumentField or a plain Go value whose type is inferred.
+type Filter struct {
+	Op      FilterOp
+	Field   string
+	Value   any
+	Filters []Filter
+}
+
+func Eq(field string, value any) Filter  { return Filter{Op: FilterOpEq, Field: field, Value: value} }
+func Ne(field string, value any) Filter  { return Filter{Op: FilterOpNe, Field: field, Value: value} }
+func Gt(field string, value any) Filter  { return Filter{Op: FilterOpGt, Field: field, Value: value} }
+func Gte(field string, value any) Filter { return Filter{Op: FilterOpGte, Field: field, Value: value} }
+func Lt(field string, value any) Filter  { return Filter{Op: FilterOpLt, Field: field, Value: value} }
+func Lte(field string, value any) Filter { return Filter{Op: FilterOpLte, Field: field, Value: value} }
+
+func In(field string, values ...any) Filter {
+	return Filter{Op: FilterOpIn, Field: field, Value: values}
+}
+
+func Contains(field string, value any) Filter {
+	return Filter{Op: FilterOpContains, Field: field, Value: value}
+}
+
+func Exists(field string, exists bool) Filter {
+	return Filter{Op: FilterOpExists, Field: field, Value: exists}
+}
+
+func And(filters ...Filter) Filter { return Filter{Op: FilterOpAnd, Filters: filters} }
+func Or(filters ...Filter) Filter  { return Filter{Op: FilterOpOr, Filters: filters} }
+func Not(filter Filter) Filter     { return Filter{Op: FilterOpNot, Filters: []Filter{filter}} }
+
+// ParseFilter builds a Filter from a Mongo-like map, e.g.
+//
+//	{"status": "active", "age": {"$gte": 18}, "$or": [{"role": "admin"}, {"role": "owner"}]}
+//
+// so that filters can be read from JSON. Several keys are combined with $and.
+func ParseFilter(m map[string]any) (Filter, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	filters := make([]Filter, 0, len(keys))
+	for _, key := range keys {
+		raw := m[key]
+		switch FilterOp(key) {
+		case FilterOpAnd, FilterOpOr:
+			list, ok := raw.([]any)
+			if !ok {
+				return Filter{}, fmt.Errorf("%w: %s expects a list", ErrInvalidFilter, key)
+			}
+			sub := make([]Filter, 0, len(list))
+			for _, item := range list {
+				itemMap, ok := item.(map[string]any)
+				if !ok {
+					return Filter{}, fmt.Errorf("%w: %s expects a list of objects", ErrInvalidFilter, key)
+				}
+				f, err := ParseFilter(itemMap)
+				if err != nil {
+					return Filter{}, err
+				}
+				sub = append(sub, f)
+			}
+			filters = append(filters, Filter{Op: FilterOp(key), Filters: sub})
+		case FilterOpNot:
+			subMap, ok := raw.(map[string]any)
+			if !ok {
+				return Filter{}, fmt.Errorf("%w: $not expects an object", ErrInvalidFilter)
+			}
+			f, err := ParseFilter(subMap)
+			if err != nil {
+				return Filter{}, err
+			}
+			filters = append(filters, Not(f))
+		default:
+			if strings.HasPrefix(key, "$") {
+				return Filter{}, fmt.Errorf("%w: unknown operator %s", ErrInvalidFilter, key)
+			}
+			fieldFilters, err := parseFieldFilter(key, raw)
+			if err != nil {
+				return Filter{}, err
+			}
+			filters = append(filters, fieldFilters...)
+		}
+	}
+
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return And(filters...), nil
+}
+
+func parseFieldFilter(field string, raw any) ([]Filter, error) {
+	ops, ok := raw.(map[string]any)
+	if !ok {
+		return []Filter{Eq(field, raw)}, nil
+	}
+	filters := make([]Filter, 0, len(ops))
+	for op, value := range ops {
+		switch FilterOp(op) {
+		case FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte, FilterOpExists, FilterOpContains:
+			filters = append(filters, Filter{Op: FilterOp(op), Field: field, Value: value})
+		case FilterOpIn:
+			list, ok := value.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: $in expects a list for field %s", ErrInvalidFilter, field)
+			}
+			filters = append(filters, In(field, list...))
+		case FilterOpNot:
+			sub, err := parseFieldFilter(field, value)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, Not(And(sub...)))
+		default:
+			return nil, fmt.Errorf("%w: unknown operator %s for field %s", ErrInvalidFilter, op, field)
+		}
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].Op < filters[j].Op })
+	return filters, nil
+}
+
+// Validate checks that the filter is well-formed before it is evaluated.
+func (f Filter) Validate() error {
+	switch f.Op {
+	case FilterOpEq, FilterOpNe, FilterOpGt, FilterOpGte, FilterOpLt, FilterOpLte, FilterOpContains:
+		if strings.TrimSpace(f.Field) == "" {
+			return fmt.Errorf("%w: %s requires a field", ErrInvalidFilter, f.Op)
+		}
+		if _, ok := fieldFromValue(f.Value); !ok {
+			return fmt.Errorf("%w: unsupported value %v for field %s", ErrInvalidFilter, f.Value, f.Field)
+		}
+	case FilterOpIn:
+		if strings.TrimSpace(f.Field) == "" {
+			return fmt.Errorf("%w: $in requires a field", ErrInvalidFilter)
+		}
+		values, ok := f.Value.([]any)
+		if !ok {
+			return fmt.Errorf("%w: $in expects a list for field %s", ErrInvalidFilter, f.Field)
+		}
+		for _, v := range values {
+			if _, ok := fieldFromValue(v); !ok {
+				return fmt.Errorf("%w: unsupported value %v for field %s", ErrInvalidFilter, v, f.Field)
+			}
+		}
+	case FilterOpExists:
+		if strings.TrimSpace(f.Field) == "" {
+			return fmt.Errorf("%w: $exists requires a field", ErrInvalidFilter)
+		}
+		if _, ok := f.Value.(bool); !ok {
+			return fmt.Errorf("%w: $exists expects a bool for field %s", ErrInvalidFilter, f.Field)
+		}
+	case FilterOpAnd, FilterOpOr:
+		for _, sub := range f.Filters {
+			if err := sub.Validate(); err != nil {
+				return err
+			}
+		}
+	case FilterOpNot:
+		if len(f.Filters) != 1 {
+			return fmt.Errorf("%w: $not expects exactly one filter", ErrInvalidFilter)
+		}
+		return f.Filters[0].Validate()
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, f.Op)
+	}
+	return nil
+}
+
+// Match reports whether the document satisfies the filter.
+// The filter is expected to be valid, see Validate.
+func (f Filter) Match(doc *Document) bool {
+	switch f.Op {
+	case FilterOpAnd:
+		for _, sub := range f.Filters {
+			if !sub.Match(doc) {
+				return false
+			}
+		}
+		return true
+	case FilterOpOr:
+		for _, sub := range f.Filters {
+			if sub.Match(doc) {
+				return true
+			}
+		}
+		return false
+	case FilterOpNot:
+		return len(f.Filters) == 1 && !f.Filters[0].Match(doc)
+	case FilterOpExists:
+		_, found := lookupField(doc, f.Field)
+		want, _ := f.Value.(bool)
+		return found == want
+	case FilterOpNe:
+		return !Eq(f.Field, f.Value).Match(doc)
+	}
+
+	field, found := lookupField(doc, f.Field)
+	if !found {
+		return false
+	}
+	switch f.Op {
+	case FilterOpEq:
+		value, ok := fieldFromValue(f.Value)
+		return ok && equalFields(field, value)
+	case FilterOpIn:
+		values, _ := f.Value.([]any)
+		for _, v := range values {
+			if value, ok := fieldFromValue(v); ok && equalFields(field, value) {
+				return true
+			}
+		}
+		return false
+	case FilterOpContains:
+		value, ok := fieldFromValue(f.Value)
+		return ok && containsValue(field, value)
+	}
+
+	value, ok := fieldFromValue(f.Value)
+	if !ok {
+		return false
+	}
+	c, ok := compareFields(field, value)
+	if !ok {
+		return false
+	}
+	switch f.Op {
+	case FilterOpGt:
+		return c > 0
+	case FilterOpGte:
+		return c >= 0
+	case FilterOpLt:
+		return c < 0
+	case FilterOpLte:
+		return c <= 0
+	}
+	return false
+}
+
+func containsValue(field, value DocumentField) bool {
+	switch field.Type {
+	case DocumentFieldTypeArray:
+		elems, _ := arrayFields(field)
+		for _, elem := range elems {
+			if equalFields(elem, value) {
+				return true
+			}
+		}
+	case DocumentFieldTypeString:
+		s, ok := field.Value.(string)
+		sub, subOK := value.Value.(string)
+		return ok && subOK && value.Type == DocumentFieldTypeString && strings.Contains(s, sub)
+	}
+	return false
+}
+
+// lookupField returns the document field addressed by name, which may be a
+// path into nested fields (see Document.GetPath).
+func lookupField(doc *Document, name string) (DocumentField, bool) {
+	return doc.GetPath(name)
+}
+
+// Find returns all documents matching the filter.
+func (s *Collection) Find(filter Filter) ([]Document, error) {
+	if err := filter.Validate(); err != nil {
+		pkgLogger.Error("[Collection Find] invalid filter", slog.Any("error", err))
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	docs := make([]Document, 0)
+	if keys, ok := s.candidates(filter); ok {
+		for key := range keys {
+			if doc, found := s.documents[key]; found && filter.Match(doc) {
+				docs = append(docs, *doc)
+			}
+		}
+		pkgLogger.Info("[Collection Find] documents matched using index", slog.Int("candidates", len(keys)), slog.Int("matched", len(docs)))
+		return docs, nil
+	}
+	for _, doc := range s.documents {
+		if filter.Match(doc) {
+			docs = append(docs, *doc)
+		}
+	}
+	pkgLogger.Info("[Collection Find] documents matched", slog.Int("matched", len(docs)))
+	return docs, nil
+}
@@ -0,0 +1,121 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUsersCollection(t *testing.T) *Collection {
+	t.Helper()
+	col := NewCollection(&CollectionConfig{PrimaryKey: "id"})
+	users := []struct {
+		id     string
+		age    int
+		status string
+		vip    bool
+	}{
+		{"u1", 17, "active", false},
+		{"u2", 25, "active", true},
+		{"u3", 31, "blocked", false},
+		{"u4", 45, "active", false},
+	}
+	for _, u := range users {
+		fields := map[string]DocumentField{
+			"id":     {Type: DocumentFieldTypeString, Value: u.id},
+			"age":    {Type: DocumentFieldTypeNumber, Value: u.age},
+			"status": {Type: DocumentFieldTypeString, Value: u.status},
+		}
+		if u.vip {
+			fields["vip"] = DocumentField{Type: DocumentFieldTypeBool, Value: true}
+		}
+		require.NoError(t, col.Put(Document{Fields: fields}))
+	}
+	return col
+}
+
+func ids(docs []Document) []string {
+	result := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		result = append(result, doc.Fields["id"].Value.(string))
+	}
+	return result
+}
+
+func TestCollection_Find(t *testing.T) {
+	col := newUsersCollection(t)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"eq", Eq("status", "blocked"), []string{"u3"}},
+		{"ne", Ne("status", "active"), []string{"u3"}},
+		{"gt", Gt("age", 31), []string{"u4"}},
+		{"gte float against int", Gte("age", 31.0), []string{"u3", "u4"}},
+		{"lt", Lt("age", 18), []string{"u1"}},
+		{"lte", Lte("age", 25), []string{"u1", "u2"}},
+		{"in", In("id", "u1", "u4", "missing"), []string{"u1", "u4"}},
+		{"exists", Exists("vip", true), []string{"u2"}},
+		{"not exists", Exists("vip", false), []string{"u1", "u3", "u4"}},
+		{"and", And(Eq("status", "active"), Gte("age", 18)), []string{"u2", "u4"}},
+		{"or", Or(Lt("age", 18), Eq("status", "blocked")), []string{"u1", "u3"}},
+		{"not", Not(Eq("status", "active")), []string{"u3"}},
+		{"type mismatch does not match", Eq("age", "25"), []string{}},
+		{"comparison on missing field", Gt("score", 1), []string{}},
+		{"contains substring", Contains("status", "lock"), []string{"u3"}},
+		{"contains on number", Contains("age", 25), []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := col.Find(tt.filter)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tt.want, ids(docs))
+		})
+	}
+}
+
+func TestCollection_Find_InvalidFilter(t *testing.T) {
+	col := newUsersCollection(t)
+
+	invalid := []Filter{
+		{Op: "$regex", Field: "name", Value: "a"},
+		Eq("", 1),
+		Gt("age", make(chan int)),
+		{Op: FilterOpIn, Field: "age", Value: 1},
+		{Op: FilterOpExists, Field: "age", Value: "yes"},
+		{Op: FilterOpNot, Filters: []Filter{Eq("a", 1), Eq("b", 2)}},
+	}
+	for _, f := range invalid {
+		_, err := col.Find(f)
+		assert.True(t, errors.Is(err, ErrInvalidFilter), "filter %+v: %v", f, err)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	col := newUsersCollection(t)
+
+	var raw map[string]any
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"status": "active",
+		"age": {"$gte": 18, "$lt": 40},
+		"$or": [{"vip": true}, {"id": {"$in": ["u4"]}}]
+	}`), &raw))
+
+	filter, err := ParseFilter(raw)
+	require.NoError(t, err)
+
+	docs, err := col.Find(filter)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"u2"}, ids(docs))
+
+	_, err = ParseFilter(map[string]any{"age": map[string]any{"$between": 1}})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+	_, err = ParseFilter(map[string]any{"$or": "x"})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
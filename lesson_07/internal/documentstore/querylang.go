@@ -0,0 +1,661 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrQuerySyntax = errors.New("[Query] Error: syntax error")
+	ErrQueryType   = errors.New("[Query] Error: type error")
+)
+
+// QueryError is a syntax or type error in a query text, Pos is the 1-based
+// column, in characters, where the problem starts.
+type QueryError struct {
+	Pos   int
+	Msg   string
+	kind  error
+	query string
+}
+
+func newQueryError(kind error, query string, offset int, msg string) *QueryError {
+	return &QueryError{Pos: utf8.RuneCountInString(query[:offset]) + 1, Msg: msg, kind: kind, query: query}
+}
+
+func (e *QueryError) Error() string {
+	// the caret line keeps the tabs of the query so the caret lines up
+	var caret strings.Builder
+	for i, r := range []rune(e.query) {
+		if i >= e.Pos-1 {
+			break
+		}
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	return fmt.Sprintf("%s at column %d: %s\n\t%s\n\t%s^", e.kind.Error(), e.Pos, e.Msg, e.query, caret.String())
+}
+
+func (e *QueryError) Is(target error) bool {
+	return target == e.kind
+}
+
+// Query is a parsed query text, see ParseQuery.
+type Query struct {
+	// Filter is nil when the query has no condition.
+	Filter *Filter
+	Sort   []SortKey
+	Limit  int
+	Offset int
+}
+
+// ListOptions returns the options to run the query with ListWithOptions.
+func (q *Query) ListOptions() ListOptions {
+	return ListOptions{Filter: q.Filter, Sort: q.Sort, Limit: q.Limit, Offset: q.Offset}
+}
+
+// ParseQuery parses a query such as
+//
+//	age >= 18 AND roles CONTAINS "admin" ORDER BY name LIMIT 10
+//
+// The grammar, keywords are case-insensitive:
+//
+//	query      = [ expr ] [ "ORDER" "BY" key { "," key } ] [ "LIMIT" int ] [ "OFFSET" int ]
+//	expr       = term { "OR" term }
+//	term       = factor { "AND" factor }
+//	factor     = "NOT" factor | "(" expr ")" | condition
+//	condition  = field ( op value | [ "NOT" ] "IN" "[" [ value { "," value } ] "]"
+//	             | [ "NOT" ] "CONTAINS" value | [ "NOT" ] "EXISTS" )
+//	op         = "=" | "==" | "!=" | "<>" | "<" | "<=" | ">" | ">="
+//	key        = field [ "ASC" | "DESC" ]
+//	value      = string | number | "TRUE" | "FALSE" | "NULL"
+//	             | "TIMESTAMP" string | "DECIMAL" string
+//
+// Fields are paths like address.city or roles[0], names with other
+// characters are quoted in backticks. Strings use double or single quotes
+// with Go escapes, TIMESTAMP takes an RFC 3339 time. LIMIT must be positive.
+func ParseQuery(src string) (*Query, error) {
+	return parseQuery(src, nil)
+}
+
+// Query parses src (see ParseQuery), checks it against the schema of the
+// collection, if it has one, and returns the matching documents.
+func (s *Collection) Query(src string) ([]Document, error) {
+	q, err := parseQuery(src, s.schema)
+	if err != nil {
+		pkgLogger.Error("[Collection Query] invalid query", slog.String("query", src), slog.Any("error", err))
+		return nil, err
+	}
+	res, err := s.ListWithOptions(q.ListOptions())
+	if err != nil {
+		return nil, err
+	}
+	return res.Documents, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokKeyword
+	tokString
+	tokNumber
+	tokOp
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	// text is the keyword in upper case, the unquoted string or field name,
+	// or the operator or punctuation itself.
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var queryKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IN": true, "CONTAINS": true, "EXISTS": true,
+	"ORDER": true, "BY": true, "ASC": true, "DESC": true, "LIMIT": true, "OFFSET": true,
+	"TRUE": true, "FALSE": true, "NULL": true, "TIMESTAMP": true, "DECIMAL": true,
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) errorf(pos int, format string, args ...any) error {
+	return newQueryError(ErrQuerySyntax, l.src, pos, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '"' || c == '\'':
+		return l.quoted(start, c)
+	case c == '`':
+		end := strings.IndexByte(l.src[start+1:], '`')
+		if end < 0 {
+			return token{}, l.errorf(start, "unterminated field name")
+		}
+		l.pos = start + end + 2
+		if end == 0 {
+			return token{}, l.errorf(start, "empty field name")
+		}
+		return token{kind: tokIdent, text: l.src[start+1 : start+1+end], pos: start}, nil
+	case c == '-' || isDigit(c):
+		l.pos++
+		for l.pos < len(l.src) && strings.IndexByte("0123456789.eE+-", l.src[l.pos]) >= 0 {
+			if (l.src[l.pos] == '+' || l.src[l.pos] == '-') && !strings.ContainsRune("eE", rune(l.src[l.pos-1])) {
+				break
+			}
+			l.pos++
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+	case isIdentByte(c) && !isDigit(c):
+		for l.pos < len(l.src) {
+			c := l.src[l.pos]
+			if c == '[' {
+				end := strings.IndexByte(l.src[l.pos:], ']')
+				if end < 0 {
+					return token{}, l.errorf(l.pos, "unterminated index")
+				}
+				l.pos += end + 1
+				continue
+			}
+			if c != '.' && !isIdentByte(c) {
+				break
+			}
+			l.pos++
+		}
+		word := l.src[start:l.pos]
+		if upper := strings.ToUpper(word); queryKeywords[upper] {
+			return token{kind: tokKeyword, text: upper, pos: start}, nil
+		}
+		if _, err := parsePath(word); err != nil {
+			return token{}, l.errorf(start, "invalid field %q", word)
+		}
+		return token{kind: tokIdent, text: word, pos: start}, nil
+	case strings.IndexByte("<>=!", c) >= 0:
+		for _, op := range []string{"<=", ">=", "<>", "!=", "==", "<", ">", "="} {
+			if strings.HasPrefix(l.src[start:], op) {
+				l.pos += len(op)
+				return token{kind: tokOp, text: op, pos: start}, nil
+			}
+		}
+	case strings.IndexByte("()[],", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, text: string(c), pos: start}, nil
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[start:])
+	return token{}, l.errorf(start, "unexpected character %q", r)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// isIdentByte reports bytes of unquoted field names, other names are quoted
+// in backticks.
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
+
+func (l *lexer) quoted(start int, quote byte) (token, error) {
+	i := start + 1
+	for ; i < len(l.src) && l.src[i] != quote; i++ {
+		if l.src[i] == '\\' {
+			i++
+		}
+	}
+	if i >= len(l.src) {
+		return token{}, l.errorf(start, "unterminated string")
+	}
+	l.pos = i + 1
+	body := l.src[start+1 : i]
+	if quote == '\'' {
+		body = strings.ReplaceAll(strings.ReplaceAll(body, `\'`, `'`), `"`, `\"`)
+	}
+	text, err := strconv.Unquote(`"` + body + `"`)
+	if err != nil {
+		return token{}, l.errorf(start, "invalid string: %v", err)
+	}
+	return token{kind: tokString, text: text, pos: start}, nil
+}
+
+type queryParser struct {
+	lex    lexer
+	tok    token
+	schema *schemaChecker
+}
+
+func parseQuery(src string, schema *schemaChecker) (*Query, error) {
+	p := &queryParser{lex: lexer{src: src}, schema: schema}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	q := &Query{}
+	if p.tok.kind != tokEOF && !p.isKeyword("ORDER", "LIMIT", "OFFSET") {
+		f, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		q.Filter = &f
+	}
+	if p.isKeyword("ORDER") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			field, err := p.field()
+			if err != nil {
+				return nil, err
+			}
+			key := SortKey{Field: field.text}
+			if p.isKeyword("ASC", "DESC") {
+				key.Order = SortOrder(strings.ToLower(p.tok.text))
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+			q.Sort = append(q.Sort, key)
+			if !p.isPunct(",") {
+				break
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, clause := range []struct {
+		keyword string
+		dst     *int
+	}{{"LIMIT", &q.Limit}, {"OFFSET", &q.Offset}} {
+		if !p.isKeyword(clause.keyword) {
+			continue
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(p.tok.text)
+		if p.tok.kind != tokNumber || err != nil || n < 0 {
+			return nil, p.errorf("%s expects a non-negative integer, got %s", clause.keyword, p.tok)
+		}
+		// ListOptions treats a zero limit as no limit
+		if n == 0 && clause.keyword == "LIMIT" {
+			return nil, p.errorf("LIMIT expects a positive integer, got %s", p.tok)
+		}
+		*clause.dst = n
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return q, nil
+}
+
+func (p *queryParser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return p.lex.errorf(p.tok.pos, format, args...)
+}
+
+func (p *queryParser) typeErrorf(pos int, format string, args ...any) error {
+	return newQueryError(ErrQueryType, p.lex.src, pos, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) isKeyword(words ...string) bool {
+	if p.tok.kind != tokKeyword {
+		return false
+	}
+	for _, w := range words {
+		if p.tok.text == w {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryParser) isPunct(s string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == s
+}
+
+func (p *queryParser) expectKeyword(word string) error {
+	if !p.isKeyword(word) {
+		return p.errorf("expected %s, got %s", word, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *queryParser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.errorf("expected %q, got %s", s, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *queryParser) field() (token, error) {
+	tok := p.tok
+	if tok.kind != tokIdent {
+		return tok, p.errorf("expected a field, got %s", tok)
+	}
+	return tok, p.advance()
+}
+
+func (p *queryParser) expr() (Filter, error) {
+	return p.binary("OR", Or, p.term)
+}
+
+func (p *queryParser) term() (Filter, error) {
+	return p.binary("AND", And, p.factor)
+}
+
+func (p *queryParser) binary(keyword string, combine func(...Filter) Filter, operand func() (Filter, error)) (Filter, error) {
+	first, err := operand()
+	if err != nil {
+		return Filter{}, err
+	}
+	filters := []Filter{first}
+	for p.isKeyword(keyword) {
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+		next, err := operand()
+		if err != nil {
+			return Filter{}, err
+		}
+		filters = append(filters, next)
+	}
+	if len(filters) == 1 {
+		return first, nil
+	}
+	return combine(filters...), nil
+}
+
+func (p *queryParser) factor() (Filter, error) {
+	switch {
+	case p.isKeyword("NOT"):
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+		f, err := p.factor()
+		if err != nil {
+			return Filter{}, err
+		}
+		return Not(f), nil
+	case p.isPunct("("):
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+		f, err := p.expr()
+		if err != nil {
+			return Filter{}, err
+		}
+		return f, p.expectPunct(")")
+	}
+	return p.condition()
+}
+
+func (p *queryParser) condition() (Filter, error) {
+	field, err := p.field()
+	if err != nil {
+		return Filter{}, err
+	}
+	fs, err := p.fieldSchema(field)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	if p.tok.kind == tokOp {
+		op := p.tok
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+		value, pos, err := p.value()
+		if err != nil {
+			return Filter{}, err
+		}
+		switch op.text {
+		case "=", "==":
+			return Eq(field.text, value), p.checkEqual(field, fs, value, pos)
+		case "!=", "<>":
+			return Ne(field.text, value), p.checkEqual(field, fs, value, pos)
+		}
+		if err := p.checkOrdered(field, fs, value, pos); err != nil {
+			return Filter{}, err
+		}
+		return map[string]func(string, any) Filter{"<": Lt, "<=": Lte, ">": Gt, ">=": Gte}[op.text](field.text, value), nil
+	}
+
+	negate := false
+	if p.isKeyword("NOT") {
+		negate = true
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+	}
+	var f Filter
+	switch {
+	case p.isKeyword("IN"):
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+		if err := p.expectPunct("["); err != nil {
+			return Filter{}, err
+		}
+		values := make([]any, 0)
+		for !p.isPunct("]") {
+			if len(values) > 0 {
+				if err := p.expectPunct(","); err != nil {
+					return Filter{}, err
+				}
+			}
+			value, pos, err := p.value()
+			if err != nil {
+				return Filter{}, err
+			}
+			if err := p.checkEqual(field, fs, value, pos); err != nil {
+				return Filter{}, err
+			}
+			values = append(values, value)
+		}
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+		f = In(field.text, values...)
+	case p.isKeyword("CONTAINS"):
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+		value, pos, err := p.value()
+		if err != nil {
+			return Filter{}, err
+		}
+		if err := p.checkContains(field, fs, value, pos); err != nil {
+			return Filter{}, err
+		}
+		f = Contains(field.text, value)
+	case p.isKeyword("EXISTS"):
+		if err := p.advance(); err != nil {
+			return Filter{}, err
+		}
+		return Exists(field.text, !negate), nil
+	default:
+		return Filter{}, p.errorf("expected an operator after %s, got %s", field, p.tok)
+	}
+	if negate {
+		return Not(f), nil
+	}
+	return f, nil
+}
+
+// value parses a literal and returns it with its position.
+func (p *queryParser) value() (DocumentField, int, error) {
+	tok := p.tok
+	var f DocumentField
+	switch {
+	case tok.kind == tokString:
+		f = DocumentField{Type: DocumentFieldTypeString, Value: tok.text}
+	case tok.kind == tokNumber:
+		if n, err := strconv.Atoi(tok.text); err == nil {
+			f = DocumentField{Type: DocumentFieldTypeNumber, Value: n}
+		} else if x, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			f = DocumentField{Type: DocumentFieldTypeNumber, Value: x}
+		} else {
+			return f, 0, p.errorf("invalid number %s", tok)
+		}
+	case p.isKeyword("TRUE", "FALSE"):
+		f = DocumentField{Type: DocumentFieldTypeBool, Value: tok.text == "TRUE"}
+	case p.isKeyword("NULL"):
+		f = DocumentField{Type: DocumentFieldTypeNull}
+	case p.isKeyword("TIMESTAMP", "DECIMAL"):
+		if err := p.advance(); err != nil {
+			return f, 0, err
+		}
+		if p.tok.kind != tokString {
+			return f, 0, p.errorf("%s expects a string, got %s", tok.text, p.tok)
+		}
+		if tok.text == "TIMESTAMP" {
+			t, err := time.Parse(time.RFC3339Nano, p.tok.text)
+			if err != nil {
+				return f, 0, p.errorf("invalid timestamp %s", p.tok)
+			}
+			f = DocumentField{Type: DocumentFieldTypeTimestamp, Value: t}
+		} else {
+			r, err := parseDecimal(p.tok.text)
+			if err != nil {
+				return f, 0, p.errorf("invalid decimal %s", p.tok)
+			}
+			f = DocumentField{Type: DocumentFieldTypeDecimal, Value: r}
+		}
+	default:
+		return f, 0, p.errorf("expected a value, got %s", tok)
+	}
+	return f, tok.pos, p.advance()
+}
+
+// fieldSchema returns the schema of the field, nil when any value is allowed.
+func (p *queryParser) fieldSchema(field token) (*FieldSchema, error) {
+	if p.schema == nil {
+		return nil, nil
+	}
+	if field.text == p.schema.primaryKey {
+		return &FieldSchema{Type: DocumentFieldTypeString}, nil
+	}
+	steps, _ := parsePath(field.text)
+	if _, ok := p.schema.schema.Fields[field.text]; ok {
+		steps = []pathStep{{key: field.text}}
+	}
+	obj := p.schema.schema
+	var fs *FieldSchema
+	for _, step := range steps {
+		switch {
+		case step.isIndex && fs != nil && fs.Type == DocumentFieldTypeArray:
+			fs = fs.Items
+		case step.isIndex:
+			return nil, p.typeErrorf(field.pos, "%s indexes a field that is not an array", field.text)
+		case obj == nil:
+			return nil, p.typeErrorf(field.pos, "%s is not an object field", field.text)
+		default:
+			fs = obj.Fields[step.key]
+			if fs == nil {
+				if obj.AllowUnknown {
+					return nil, nil
+				}
+				return nil, p.typeErrorf(field.pos, "unknown field %s", field.text)
+			}
+		}
+		if fs == nil || fs.Type == "" {
+			return nil, nil
+		}
+		obj = nil
+		if fs.Type == DocumentFieldTypeObject {
+			obj = fs.Object
+			if obj == nil {
+				return nil, nil
+			}
+		}
+	}
+	return fs, nil
+}
+
+func fitsSchema(fs *FieldSchema, value DocumentField) bool {
+	if fs == nil || fs.Type == "" {
+		return true
+	}
+	if value.Type == DocumentFieldTypeNull {
+		return fs.Nullable || fs.Type == DocumentFieldTypeNull
+	}
+	return orderType(fs.Type) == orderType(value.Type)
+}
+
+func (p *queryParser) checkEqual(field token, fs *FieldSchema, value DocumentField, pos int) error {
+	if !fitsSchema(fs, value) {
+		return p.typeErrorf(pos, "%s is %s, cannot compare with %s", field.text, fs.Type, value.Type)
+	}
+	return nil
+}
+
+func (p *queryParser) checkOrdered(field token, fs *FieldSchema, value DocumentField, pos int) error {
+	switch orderType(value.Type) {
+	case DocumentFieldTypeNumber, DocumentFieldTypeString, DocumentFieldTypeTimestamp:
+	default:
+		return p.typeErrorf(pos, "%s values have no order", value.Type)
+	}
+	return p.checkEqual(field, fs, value, pos)
+}
+
+func (p *queryParser) checkContains(field token, fs *FieldSchema, value DocumentField, pos int) error {
+	if fs == nil {
+		return nil
+	}
+	switch fs.Type {
+	case DocumentFieldTypeArray:
+		if !fitsSchema(fs.Items, value) {
+			return p.typeErrorf(pos, "%s holds %s elements, cannot contain %s", field.text, fs.Items.Type, value.Type)
+		}
+	case DocumentFieldTypeString:
+		if value.Type != DocumentFieldTypeString {
+			return p.typeErrorf(pos, "%s is string, cannot contain %s", field.text, value.Type)
+		}
+	default:
+		return p.typeErrorf(field.pos, "CONTAINS needs an array or string field, %s is %s", field.text, fs.Type)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package documentstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func memberDoc(id string, age int, roles ...string) Document {
+	roleFields := make([]DocumentField, len(roles))
+	for i, role := range roles {
+		roleFields[i] = DocumentField{Type: DocumentFieldTypeString, Value: role}
+	}
+	return Document{Fields: map[string]DocumentField{
+		"id":    {Type: DocumentFieldTypeString, Value: id},
+		"name":  {Type: DocumentFieldTypeString, Value: "name-" + id},
+		"age":   {Type: DocumentFieldTypeNumber, Value: age},
+		"roles": {Type: DocumentFieldTypeArray, Value: roleFields},
+	}}
+}
+
+func membersSchema() *Schema {
+	return &Schema{Fields: map[string]*FieldSchema{
+		"name":    {Type: DocumentFieldTypeString},
+		"age":     {Type: DocumentFieldTypeNumber},
+		"roles":   {Type: DocumentFieldTypeArray, Items: &FieldSchema{Type: DocumentFieldTypeString}},
+		"joined":  {Type: DocumentFieldTypeTimestamp, Nullable: true},
+		"address": {Type: DocumentFieldTypeObject, Object: &Schema{Fields: map[string]*FieldSchema{"city": {Type: DocumentFieldTypeString}}}},
+	}}
+}
+
+func newMembersCollection(t *testing.T) *Collection {
+	t.Helper()
+	members, err := NewStore().CreateCollection("members", &CollectionConfig{PrimaryKey: "id", Schema: membersSchema()})
+	require.NoError(t, err)
+	for _, doc := range []Document{
+		memberDoc("m1", 16, "guest"),
+		memberDoc("m2", 18, "admin", "dev"),
+		memberDoc("m3", 40, "dev"),
+		memberDoc("m4", 52, "admin"),
+	} {
+		require.NoError(t, members.Put(doc))
+	}
+	return members
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(`age >= 18 and (roles CONTAINS "admin" OR NOT name = 'x') ORDER BY name DESC, age LIMIT 10 OFFSET 5`)
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		Filter: &Filter{Op: FilterOpAnd, Filters: []Filter{
+			Gte("age", DocumentField{Type: DocumentFieldTypeNumber, Value: 18}),
+			Or(
+				Contains("roles", DocumentField{Type: DocumentFieldTypeString, Value: "admin"}),
+				Not(Eq("name", DocumentField{Type: DocumentFieldTypeString, Value: "x"})),
+			),
+		}},
+		Sort:   []SortKey{{Field: "name", Order: SortDesc}, {Field: "age"}},
+		Limit:  10,
+		Offset: 5,
+	}, q)
+
+	q, err = ParseQuery("ORDER BY `full name`")
+	require.NoError(t, err)
+	assert.Nil(t, q.Filter)
+	assert.Equal(t, []SortKey{{Field: "full name"}}, q.Sort)
+
+	q, err = ParseQuery(`joined < TIMESTAMP "2024-01-02T03:04:05Z" AND price <> DECIMAL "0.10" AND tags[0] NOT IN [1, -2.5, true, null]`)
+	require.NoError(t, err)
+	require.Len(t, q.Filter.Filters, 3)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), q.Filter.Filters[0].Value.(DocumentField).Value)
+	assert.Equal(t, FilterOpNot, q.Filter.Filters[2].Op)
+	assert.Len(t, q.Filter.Filters[2].Filters[0].Value, 4)
+}
+
+func TestParseQuery_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{`age >=`, 7},
+		{`age 18`, 5},
+		{`age > 18 AND`, 13},
+		{`(age > 18`, 10},
+		{`name = "open`, 8},
+		{`age > 18 LIMIT -1`, 16},
+		{`age > 18 ORDER name`, 16},
+		{`roles IN ["a" "b"]`, 15},
+		{`age ~ 1`, 5},
+		{`joined > TIMESTAMP "yesterday"`, 20},
+		{`age > 18 age`, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseQuery(tt.query)
+			require.ErrorIs(t, err, ErrQuerySyntax)
+			var qerr *QueryError
+			require.ErrorAs(t, err, &qerr)
+			assert.Equal(t, tt.pos, qerr.Pos, qerr.Error())
+		})
+	}
+}
+
+func TestQueryError_Position(t *testing.T) {
+	_, err := ParseQuery("city = \"Kyiv – Київ\"\tAND")
+	var qerr *QueryError
+	require.ErrorAs(t, err, &qerr)
+	assert.Equal(t, 25, qerr.Pos, "columns count characters")
+	assert.Equal(t, "[Query] Error: syntax error at column 25: expected a field, got end of query\n"+
+		"\tcity = \"Kyiv – Київ\"\tAND\n"+
+		"\t                    \t   ^", err.Error())
+
+	_, err = ParseQuery("name = «x»")
+	require.ErrorAs(t, err, &qerr)
+	assert.Equal(t, 8, qerr.Pos)
+	assert.Contains(t, qerr.Msg, `'«'`)
+}
+
+func TestCollection_Query(t *testing.T) {
+	members := newMembersCollection(t)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`age >= 18 AND roles CONTAINS "admin" ORDER BY name LIMIT 10`, []string{"m2", "m4"}},
+		{`roles NOT CONTAINS "admin" ORDER BY age DESC`, []string{"m3", "m1"}},
+		{`id IN ["m1", "m3"] OR age > 50 ORDER BY id`, []string{"m1", "m3", "m4"}},
+		{`NOT (age < 18 OR age > 50) ORDER BY age`, []string{"m2", "m3"}},
+		{`joined NOT EXISTS ORDER BY id LIMIT 2 OFFSET 1`, []string{"m2", "m3"}},
+		{`name CONTAINS "m4"`, []string{"m4"}},
+		{``, []string{"m1", "m2", "m3", "m4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			docs, err := members.Query(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(docs))
+		})
+	}
+
+	_, err := members.Query("age > 18 LIMIT 0")
+	require.ErrorIs(t, err, ErrQuerySyntax)
+	var qerr *QueryError
+	require.ErrorAs(t, err, &qerr)
+	assert.Equal(t, 16, qerr.Pos)
+}
+
+func TestCollection_Query_TypeErrors(t *testing.T) {
+	members := newMembersCollection(t)
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{`age = "18"`, 7},
+		{`id = 1`, 6},
+		{`nickname = "x"`, 1},
+		{`address.zip = "x"`, 1},
+		{`address.city > 1`, 16},
+		{`name[0] = "x"`, 1},
+		{`roles CONTAINS 1`, 16},
+		{`age CONTAINS 1`, 1},
+		{`name = null`, 8},
+		{`age IN [1, "2"]`, 12},
+		{`age > true`, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := members.Query(tt.query)
+			require.ErrorIs(t, err, ErrQueryType)
+			var qerr *QueryError
+			require.ErrorAs(t, err, &qerr)
+			assert.Equal(t, tt.pos, qerr.Pos, qerr.Error())
+		})
+	}
+
+	docs, err := members.Query(`joined = null AND address.city = "Kyiv"`)
+	require.NoError(t, err)
+	assert.Empty(t, docs)
+}
@@ -0,0 +1,115 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var (
+	ErrRevisionConflict = errors.New("[Collection] Error: revision conflict")
+)
+
+// RevisionConflictError is returned by compare-and-swap operations when the
+// stored document revision differs from the expected one.
+// Actual is 0 when the document does not exist.
+type RevisionConflictError struct {
+	Key      string
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *RevisionConflictError) Error() string {
+	return fmt.Sprintf("%s: document '%s' has revision %d, expected %d",
+		ErrRevisionConflict.Error(), e.Key, e.Actual, e.Expected)
+}
+
+func (e *RevisionConflictError) Is(target error) bool {
+	return target == ErrRevisionConflict
+}
+
+// checkRevisionLocked compares the stored revision of key with expected.
+// Must be called with the collection lock held.
+func (s *Collection) checkRevisionLocked(key string, expected uint64) error {
+	var actual uint64
+	if current, ok := s.documents[key]; ok {
+		actual = current.Revision
+	}
+	if actual != expected {
+		return &RevisionConflictError{Key: key, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// PutIfRevision stores the document only if the stored one still has the
+// expected revision. Revision 0 means the document must not exist yet.
+func (s *Collection) PutIfRevision(doc Document, revision uint64) error {
+	keyValue, err := s.documentKey(doc)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped {
+		pkgLogger.Error("[Collection PutIfRevision] Error: collection was deleted", slog.String("key", keyValue))
+		return ErrCollectionNotFound
+	}
+	if err := s.checkRevisionLocked(keyValue, revision); err != nil {
+		pkgLogger.Warn("[Collection PutIfRevision] revision conflict", slog.String("key", keyValue), slog.Any("error", err))
+		return err
+	}
+	if err := s.putLocked(keyValue, &doc); err != nil {
+		pkgLogger.Error("[Collection PutIfRevision] Error: document rejected", slog.String("key", keyValue), slog.Any("error", err))
+		return err
+	}
+	pkgLogger.Info(fmt.Sprintf("[Collection PutIfRevision] Document with key '%s' stored", keyValue), slog.Uint64("revision", doc.Revision))
+	return nil
+}
+
+// DeleteIfRevision deletes the document only if it still has the expected revision.
+func (s *Collection) DeleteIfRevision(key string, revision uint64) error {
+	if strings.TrimSpace(key) == "" {
+		pkgLogger.Error("[Collection DeleteIfRevision] Error: key is empty")
+		return ErrKeyEmpty
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped {
+		pkgLogger.Error("[Collection DeleteIfRevision] Error: collection was deleted", slog.String("key", key))
+		return ErrCollectionNotFound
+	}
+	if _, ok := s.documents[key]; !ok {
+		pkgLogger.Error(fmt.Sprintf("[Collection DeleteIfRevision] Document with key '%s' not found", key))
+		return ErrDocumentNotFound
+	}
+	if err := s.checkRevisionLocked(key, revision); err != nil {
+		pkgLogger.Warn("[Collection DeleteIfRevision] revision conflict", slog.String("key", key), slog.Any("error", err))
+		return err
+	}
+	if err := s.deleteLocked(key); err != nil {
+		pkgLogger.Error(fmt.Sprintf("[Collection DeleteIfRevision] Document with key '%s' not deleted", key), slog.Any("error", err))
+		return err
+	}
+	pkgLogger.Info(fmt.Sprintf("[Collection DeleteIfRevision] Document with key '%s' deleted", key))
+	return nil
+}
+
+// restore puts a document loaded from a dump keeping its revision.
+// Documents from dumps without revisions get a new one.
+func (s *Collection) restore(doc Document) error {
+	keyValue, err := s.documentKey(doc)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc.Revision == 0 {
+		return s.writeLocked(keyValue, &doc, "")
+	}
+	if err := s.storeLocked(keyValue, &doc); err != nil {
+		return err
+	}
+	s.revision = max(s.revision, doc.Revision)
+	return nil
+}
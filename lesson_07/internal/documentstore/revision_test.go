@@ -0,0 +1,122 @@
+package documentstore
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counterDoc(id string, value int) Document {
+	return Document{Fields: map[string]DocumentField{
+		"id":    {Type: DocumentFieldTypeString, Value: id},
+		"value": {Type: DocumentFieldTypeNumber, Value: value},
+	}}
+}
+
+func TestRevision_AssignedOnWrite(t *testing.T) {
+	col := NewCollection(nil)
+
+	require.NoError(t, col.Put(counterDoc("a", 1)))
+	require.NoError(t, col.Put(counterDoc("b", 1)))
+	a, _ := col.Get("a")
+	b, _ := col.Get("b")
+	assert.Equal(t, uint64(1), a.Revision)
+	assert.Equal(t, uint64(2), b.Revision)
+
+	doc := counterDoc("a", 2)
+	doc.Revision = 100 // ignored by Put
+	require.NoError(t, col.Put(doc))
+	a, _ = col.Get("a")
+	assert.Equal(t, uint64(3), a.Revision)
+
+	updated, err := col.Update("a", Inc("value", 1))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), updated.Revision)
+
+	for _, d := range col.List() {
+		assert.NotZero(t, d.Revision)
+	}
+}
+
+func TestPutIfRevision(t *testing.T) {
+	col := NewCollection(nil)
+
+	require.NoError(t, col.PutIfRevision(counterDoc("a", 1), 0))
+	err := col.PutIfRevision(counterDoc("a", 2), 0)
+	var conflict *RevisionConflictError
+	require.True(t, errors.As(err, &conflict))
+	assert.Equal(t, RevisionConflictError{Key: "a", Expected: 0, Actual: 1}, *conflict)
+
+	current, _ := col.Get("a")
+	require.NoError(t, col.PutIfRevision(counterDoc("a", 2), current.Revision))
+	assert.ErrorIs(t, col.PutIfRevision(counterDoc("a", 3), current.Revision), ErrRevisionConflict)
+
+	assert.ErrorIs(t, col.PutIfRevision(counterDoc("missing", 1), 5), ErrRevisionConflict)
+	assert.ErrorIs(t, col.PutIfRevision(Document{}, 0), ErrEmptyDocument)
+}
+
+func TestDeleteIfRevision(t *testing.T) {
+	col := NewCollection(nil)
+	require.NoError(t, col.Put(counterDoc("a", 1)))
+	current, _ := col.Get("a")
+
+	assert.ErrorIs(t, col.DeleteIfRevision("a", current.Revision+1), ErrRevisionConflict)
+	require.NoError(t, col.DeleteIfRevision("a", current.Revision))
+	assert.ErrorIs(t, col.DeleteIfRevision("a", current.Revision), ErrDocumentNotFound)
+	assert.ErrorIs(t, col.DeleteIfRevision(" ", 1), ErrKeyEmpty)
+}
+
+func TestPutIfRevision_ConcurrentIncrements(t *testing.T) {
+	col := NewCollection(nil)
+	require.NoError(t, col.Put(counterDoc("c", 0)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				current, err := col.Get("c")
+				if !assert.NoError(t, err) {
+					return
+				}
+				next := counterDoc("c", current.Fields["value"].Value.(int)+1)
+				err = col.PutIfRevision(next, current.Revision)
+				if err == nil || !assert.ErrorIs(t, err, ErrRevisionConflict) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	doc, _ := col.Get("c")
+	assert.Equal(t, 20, doc.Fields["value"].Value)
+}
+
+func TestRevision_SurvivesDump(t *testing.T) {
+	s := NewStore()
+	col, _ := s.CreateCollection("counters", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, col.Put(counterDoc("a", 1)))
+	require.NoError(t, col.Put(counterDoc("b", 1)))
+	require.NoError(t, col.Put(counterDoc("b", 2)))
+	require.NoError(t, col.Delete("b"))
+
+	data, err := s.Dump()
+	require.NoError(t, err)
+	s2, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+	col2, _ := s2.GetCollection("counters")
+
+	a, err := col2.Get("a")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), a.Revision)
+	require.NoError(t, col2.PutIfRevision(counterDoc("a", 2), 1))
+
+	// the counter continues after the deleted document's revision
+	a, _ = col2.Get("a")
+	assert.Equal(t, uint64(4), a.Revision)
+}
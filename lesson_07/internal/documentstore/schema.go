@@ -0,0 +1,356 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidSchema   = errors.New("[Collection] Error: invalid schema")
+	ErrSchemaViolation = errors.New("[Collection] Error: document does not match schema")
+)
+
+// Schema describes the documents of a collection. Put rejects documents that
+// do not match it with a *SchemaError listing every offending field.
+//
+//	schema := &Schema{Fields: map[string]*FieldSchema{
+//		"id":    {Type: DocumentFieldTypeString, Required: true},
+//		"email": {Type: DocumentFieldTypeString, Pattern: `^[^@]+@[^@]+$`},
+//		"role":  {Type: DocumentFieldTypeString, Enum: []any{"admin", "user"}},
+//	}}
+type Schema struct {
+	Fields map[string]*FieldSchema `json:"fields"`
+	// AllowUnknown accepts fields that are not listed in Fields.
+	AllowUnknown bool `json:"allowUnknown,omitempty"`
+}
+
+type FieldSchema struct {
+	// Type of the field, empty accepts any type.
+	Type     DocumentFieldType `json:"type,omitempty"`
+	Required bool              `json:"required,omitempty"`
+	// Nullable also accepts null fields.
+	Nullable bool `json:"nullable,omitempty"`
+	// Object describes the fields of a nested object.
+	Object *Schema `json:"object,omitempty"`
+	// Items describes the elements of an array.
+	Items *FieldSchema `json:"items,omitempty"`
+	// Min and Max bound numbers and decimals, and the length of strings
+	// (in characters), arrays and binaries.
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// Integer requires numbers and decimals to be whole.
+	Integer bool `json:"integer,omitempty"`
+	// Pattern is a regular expression strings must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Enum lists the allowed values, compared like Eq filter values.
+	Enum []any `json:"-"`
+}
+
+type fieldSchemaJSON FieldSchema
+
+// MarshalJSON stores enum values as document fields, so their types survive dumps.
+func (f FieldSchema) MarshalJSON() ([]byte, error) {
+	enum := make([]DocumentField, 0, len(f.Enum))
+	for _, v := range f.Enum {
+		field, ok := fieldFromValue(v)
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported enum value %v", ErrInvalidSchema, v)
+		}
+		enum = append(enum, field)
+	}
+	return json.Marshal(struct {
+		fieldSchemaJSON
+		Enum []DocumentField `json:"enum,omitempty"`
+	}{fieldSchemaJSON(f), enum})
+}
+
+func (f *FieldSchema) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		fieldSchemaJSON
+		Enum []DocumentField `json:"enum,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*f = FieldSchema(raw.fieldSchemaJSON)
+	f.Enum = nil
+	for _, v := range raw.Enum {
+		f.Enum = append(f.Enum, v)
+	}
+	return nil
+}
+
+// SchemaError is returned by Put for documents that do not match the schema.
+// Paths are JSON pointers (RFC 6901), e.g. "/address/city" or "/tags/1".
+// Constraint violations are reported as FieldErrorInvalid with the constraint
+// in Expected.
+type SchemaError struct {
+	Fields []FieldError
+}
+
+func (e *SchemaError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrSchemaViolation.Error(), strings.Join(msgs, "; "))
+}
+
+func (e *SchemaError) Is(target error) bool {
+	return target == ErrSchemaViolation
+}
+
+// schemaChecker validates documents against a schema with compiled patterns.
+// The primary key field is always allowed at the top level.
+type schemaChecker struct {
+	schema     *Schema
+	primaryKey string
+	patterns   map[string]*regexp.Regexp
+}
+
+// compileSchema validates the schema itself. A nil schema gives a nil checker.
+func compileSchema(schema *Schema) (*schemaChecker, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	c := &schemaChecker{schema: schema, patterns: make(map[string]*regexp.Regexp)}
+	if err := c.compileObject("", schema); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *schemaChecker) compileObject(path string, schema *Schema) error {
+	for name, fs := range schema.Fields {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w: %s: empty field name", ErrInvalidSchema, path)
+		}
+		if fs == nil {
+			return fmt.Errorf("%w: %s: field has no schema", ErrInvalidSchema, pointerPath(path, name))
+		}
+		if err := c.compileField(pointerPath(path, name), fs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *schemaChecker) compileField(path string, fs *FieldSchema) error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidSchema, path, fmt.Sprintf(format, args...))
+	}
+	switch fs.Type {
+	case "", DocumentFieldTypeString, DocumentFieldTypeNumber, DocumentFieldTypeBool,
+		DocumentFieldTypeArray, DocumentFieldTypeObject, DocumentFieldTypeTimestamp,
+		DocumentFieldTypeBinary, DocumentFieldTypeNull, DocumentFieldTypeDecimal:
+	default:
+		return invalid("unknown type %q", fs.Type)
+	}
+	if fs.Object != nil && fs.Type != DocumentFieldTypeObject {
+		return invalid("object schema requires type object")
+	}
+	if fs.Items != nil && fs.Type != DocumentFieldTypeArray {
+		return invalid("items schema requires type array")
+	}
+	if fs.Min != nil || fs.Max != nil {
+		switch fs.Type {
+		case DocumentFieldTypeNumber, DocumentFieldTypeDecimal, DocumentFieldTypeString,
+			DocumentFieldTypeArray, DocumentFieldTypeBinary:
+		default:
+			return invalid("min and max do not apply to type %q", fs.Type)
+		}
+		if fs.Min != nil && fs.Max != nil && *fs.Min > *fs.Max {
+			return invalid("min %v is greater than max %v", *fs.Min, *fs.Max)
+		}
+	}
+	if fs.Integer && fs.Type != DocumentFieldTypeNumber && fs.Type != DocumentFieldTypeDecimal {
+		return invalid("integer requires type number or decimal")
+	}
+	if fs.Pattern != "" {
+		if fs.Type != DocumentFieldTypeString {
+			return invalid("pattern requires type string")
+		}
+		re, err := regexp.Compile(fs.Pattern)
+		if err != nil {
+			return invalid("%v", err)
+		}
+		c.patterns[fs.Pattern] = re
+	}
+	for _, v := range fs.Enum {
+		if _, ok := fieldFromValue(v); !ok {
+			return invalid("unsupported enum value %v", v)
+		}
+	}
+	if fs.Object != nil {
+		if err := c.compileObject(path, fs.Object); err != nil {
+			return err
+		}
+	}
+	if fs.Items != nil {
+		return c.compileField(pointerPath(path, "items"), fs.Items)
+	}
+	return nil
+}
+
+// check returns a *SchemaError when the fields do not match the schema.
+func (c *schemaChecker) check(fields map[string]DocumentField) error {
+	var errs []FieldError
+	c.checkObject("", c.schema, fields, &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return &SchemaError{Fields: errs}
+}
+
+func (c *schemaChecker) checkObject(path string, schema *Schema, fields map[string]DocumentField, errs *[]FieldError) {
+	for name, fs := range schema.Fields {
+		f, ok := fields[name]
+		if !ok {
+			if fs.Required {
+				*errs = append(*errs, FieldError{Path: pointerPath(path, name), Kind: FieldErrorMissing, Expected: schemaTypeName(fs)})
+			}
+			continue
+		}
+		c.checkField(pointerPath(path, name), fs, f, errs)
+	}
+	if schema.AllowUnknown {
+		return
+	}
+	for name, f := range fields {
+		if path == "" && name == c.primaryKey {
+			continue
+		}
+		if _, ok := schema.Fields[name]; !ok {
+			*errs = append(*errs, FieldError{Path: pointerPath(path, name), Kind: FieldErrorUnknown, Actual: string(f.Type)})
+		}
+	}
+}
+
+func (c *schemaChecker) checkField(path string, fs *FieldSchema, f DocumentField, errs *[]FieldError) {
+	if f.Type == DocumentFieldTypeNull && fs.Nullable {
+		return
+	}
+	if fs.Type != "" && f.Type != fs.Type {
+		*errs = append(*errs, FieldError{Path: path, Kind: FieldErrorTypeMismatch, Expected: schemaTypeName(fs), Actual: string(f.Type)})
+		return
+	}
+	invalid := func(expected string) {
+		*errs = append(*errs, FieldError{Path: path, Kind: FieldErrorInvalid, Expected: expected, Actual: fieldString(f)})
+	}
+	if fs.Min != nil || fs.Max != nil {
+		if m, ok := fieldMeasure(f); ok {
+			if fs.Min != nil && compareMeasure(m, *fs.Min) < 0 {
+				invalid(">= " + formatFloat(*fs.Min))
+			}
+			if fs.Max != nil && compareMeasure(m, *fs.Max) > 0 {
+				invalid("<= " + formatFloat(*fs.Max))
+			}
+		}
+	}
+	if fs.Integer {
+		if r, ok := toRat(f.Value); ok && !r.IsInt() {
+			invalid("integer")
+		}
+	}
+	if re := c.patterns[fs.Pattern]; re != nil {
+		if s, ok := f.Value.(string); ok && !re.MatchString(s) {
+			invalid("match " + fs.Pattern)
+		}
+	}
+	if len(fs.Enum) > 0 && !inEnum(f, fs.Enum) {
+		allowed := make([]string, len(fs.Enum))
+		for i, v := range fs.Enum {
+			field, _ := fieldFromValue(v)
+			allowed[i] = fieldString(field)
+		}
+		invalid("one of " + strings.Join(allowed, ", "))
+	}
+	switch {
+	case fs.Object != nil:
+		if fields, ok := objectFields(f); ok {
+			c.checkObject(path, fs.Object, fields, errs)
+		}
+	case fs.Items != nil:
+		arr := reflect.ValueOf(f.Value)
+		if arr.Kind() != reflect.Slice && arr.Kind() != reflect.Array {
+			return
+		}
+		for i := 0; i < arr.Len(); i++ {
+			elemPath := pointerPath(path, strconv.Itoa(i))
+			elem, ok := fieldFromValue(arr.Index(i).Interface())
+			if !ok {
+				*errs = append(*errs, FieldError{Path: elemPath, Kind: FieldErrorTypeMismatch,
+					Expected: schemaTypeName(fs.Items), Actual: fmt.Sprintf("%T", arr.Index(i).Interface())})
+				continue
+			}
+			c.checkField(elemPath, fs.Items, elem, errs)
+		}
+	}
+}
+
+// fieldMeasure returns what Min and Max bound: the field itself for numbers
+// and decimals, the length for strings, arrays and binaries.
+func fieldMeasure(f DocumentField) (DocumentField, bool) {
+	switch f.Type {
+	case DocumentFieldTypeNumber, DocumentFieldTypeDecimal:
+		return f, true
+	case DocumentFieldTypeString:
+		s, ok := f.Value.(string)
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: utf8.RuneCountInString(s)}, ok
+	case DocumentFieldTypeArray, DocumentFieldTypeBinary:
+		v := reflect.ValueOf(f.Value)
+		if !v.IsValid() {
+			return DocumentField{Type: DocumentFieldTypeNumber, Value: 0}, true
+		}
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return DocumentField{}, false
+		}
+		return DocumentField{Type: DocumentFieldTypeNumber, Value: v.Len()}, true
+	}
+	return DocumentField{}, false
+}
+
+func compareMeasure(f DocumentField, bound float64) int {
+	c, _ := compareFields(f, DocumentField{Type: DocumentFieldTypeNumber, Value: bound})
+	return c
+}
+
+func inEnum(f DocumentField, enum []any) bool {
+	for _, v := range enum {
+		if allowed, ok := fieldFromValue(v); ok && equalFields(f, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// pointerPath appends a reference token to a JSON pointer.
+func pointerPath(path, token string) string {
+	return path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func schemaTypeName(fs *FieldSchema) string {
+	if fs.Type == "" {
+		return "any"
+	}
+	return string(fs.Type)
+}
+
+func fieldString(f DocumentField) string {
+	if s, ok := f.Value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", plainValue(f.Value))
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
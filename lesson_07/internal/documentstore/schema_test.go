@@ -0,0 +1,159 @@
+package documentstore
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+
+func usersSchema() *Schema {
+	return &Schema{Fields: map[string]*FieldSchema{
+		"email": {Type: DocumentFieldTypeString, Required: true, Pattern: `^[^@]+@[^@]+$`},
+		"age":   {Type: DocumentFieldTypeNumber, Min: floatPtr(0), Max: floatPtr(150)},
+		"role":  {Type: DocumentFieldTypeString, Enum: []any{"admin", "user"}},
+		"limit": {Type: DocumentFieldTypeDecimal, Enum: []any{big.NewRat(1, 2), 10}},
+		"tags":  {Type: DocumentFieldTypeArray, Max: floatPtr(3), Items: &FieldSchema{Type: DocumentFieldTypeString, Min: floatPtr(1)}},
+		"phone": {Type: DocumentFieldTypeString, Nullable: true},
+		"address": {Type: DocumentFieldTypeObject, Object: &Schema{Fields: map[string]*FieldSchema{
+			"city": {Type: DocumentFieldTypeString, Required: true},
+			"zip":  {Type: DocumentFieldTypeNumber},
+		}}},
+	}}
+}
+
+func userFields(id string, extra map[string]any) Document {
+	doc := Document{Fields: map[string]DocumentField{
+		"id":    {Type: DocumentFieldTypeString, Value: id},
+		"email": {Type: DocumentFieldTypeString, Value: id + "@example.com"},
+	}}
+	for name, v := range extra {
+		f, _ := fieldFromValue(v)
+		doc.Fields[name] = f
+	}
+	return doc
+}
+
+func TestPut_Schema(t *testing.T) {
+	s := NewStore()
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: usersSchema()})
+	require.NoError(t, err)
+
+	require.NoError(t, users.Put(userFields("u1", map[string]any{
+		"age":     30,
+		"role":    "admin",
+		"limit":   big.NewRat(10, 1),
+		"tags":    []any{"a", "b"},
+		"phone":   nil,
+		"address": map[string]any{"city": "Kyiv", "zip": 1001},
+	})))
+
+	err = users.Put(Document{Fields: map[string]DocumentField{
+		"id":      {Type: DocumentFieldTypeString, Value: "u2"},
+		"age":     {Type: DocumentFieldTypeNumber, Value: -1},
+		"role":    {Type: DocumentFieldTypeString, Value: "root"},
+		"limit":   {Type: DocumentFieldTypeDecimal, Value: big.NewRat(1, 4)},
+		"tags":    {Type: DocumentFieldTypeArray, Value: []any{"a", "", true}},
+		"phone":   {Type: DocumentFieldTypeNumber, Value: 5},
+		"address": {Type: DocumentFieldTypeObject, Value: map[string]any{"zip": "1001"}},
+		"extra":   {Type: DocumentFieldTypeBool, Value: true},
+	}})
+	require.ErrorIs(t, err, ErrSchemaViolation)
+	var serr *SchemaError
+	require.ErrorAs(t, err, &serr)
+	kinds := make(map[string]FieldErrorKind)
+	for _, f := range serr.Fields {
+		kinds[f.Path] = f.Kind
+	}
+	assert.Equal(t, map[string]FieldErrorKind{
+		"/address/city": FieldErrorMissing,
+		"/address/zip":  FieldErrorTypeMismatch,
+		"/age":          FieldErrorInvalid,
+		"/email":        FieldErrorMissing,
+		"/extra":        FieldErrorUnknown,
+		"/limit":        FieldErrorInvalid,
+		"/phone":        FieldErrorTypeMismatch,
+		"/role":         FieldErrorInvalid,
+		"/tags/1":       FieldErrorInvalid,
+		"/tags/2":       FieldErrorTypeMismatch,
+	}, kinds)
+	assert.Contains(t, err.Error(), `/age: invalid value: expected >= 0, got -1`)
+	assert.Contains(t, err.Error(), `/role: invalid value: expected one of "admin", "user", got "root"`)
+
+	_, err = users.Get("u2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+	t.Run("update is checked too", func(t *testing.T) {
+		_, err := users.Update("u1", Set("tags", []any{"a", "b", "c", "d"}))
+		assert.ErrorIs(t, err, ErrSchemaViolation)
+		_, err = users.Update("u1", Unset("email"))
+		assert.ErrorIs(t, err, ErrSchemaViolation)
+	})
+
+	t.Run("unknown fields can be allowed", func(t *testing.T) {
+		schema := usersSchema()
+		schema.AllowUnknown = true
+		loose, err := s.CreateCollection("loose", &CollectionConfig{PrimaryKey: "id", Schema: schema})
+		require.NoError(t, err)
+		assert.NoError(t, loose.Put(userFields("u1", map[string]any{"extra": true})))
+	})
+}
+
+func TestCreateCollection_InvalidSchema(t *testing.T) {
+	tests := []struct {
+		name  string
+		field *FieldSchema
+	}{
+		{"unknown type", &FieldSchema{Type: "date"}},
+		{"bad pattern", &FieldSchema{Type: DocumentFieldTypeString, Pattern: "("}},
+		{"pattern on number", &FieldSchema{Type: DocumentFieldTypeNumber, Pattern: "1"}},
+		{"min above max", &FieldSchema{Type: DocumentFieldTypeNumber, Min: floatPtr(2), Max: floatPtr(1)}},
+		{"min on bool", &FieldSchema{Type: DocumentFieldTypeBool, Min: floatPtr(1)}},
+		{"items on object", &FieldSchema{Type: DocumentFieldTypeObject, Items: &FieldSchema{}}},
+		{"nested", &FieldSchema{Type: DocumentFieldTypeArray, Items: &FieldSchema{Type: "date"}}},
+		{"nil field", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := &Schema{Fields: map[string]*FieldSchema{"value": tt.field}}
+			_, err := NewStore().CreateCollection("c", &CollectionConfig{PrimaryKey: "id", Schema: schema})
+			assert.ErrorIs(t, err, ErrInvalidSchema)
+		})
+	}
+}
+
+func TestDump_KeepsSchema(t *testing.T) {
+	s := NewStore()
+	_, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: usersSchema()})
+	require.NoError(t, err)
+	dump, err := s.Dump()
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `"schema": {`)
+
+	restored, err := NewStoreFromDump(dump)
+	require.NoError(t, err)
+	users, err := restored.GetCollection("users")
+	require.NoError(t, err)
+	assert.NoError(t, users.Put(userFields("u1", map[string]any{"limit": big.NewRat(1, 2), "role": "user"})))
+	assert.ErrorIs(t, users.Put(userFields("u2", map[string]any{"limit": 3})), ErrSchemaViolation)
+	assert.ErrorIs(t, users.Put(userFields("u3", map[string]any{"role": "root"})), ErrSchemaViolation)
+}
+
+func TestOpenStore_ReplaysSchema(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+	s, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	_, err = s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Schema: usersSchema()})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	reopened, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	defer reopened.Close()
+	users, err := reopened.GetCollection("users")
+	require.NoError(t, err)
+	assert.ErrorIs(t, users.Put(userFields("u1", map[string]any{"age": 200})), ErrSchemaViolation)
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
@@ -40,12 +41,27 @@ func SetLogger(l *slog.Logger) {
 type Store struct {
 	collections map[string]*Collection
 	mu          sync.RWMutex
+	// txMu serializes transactions, see Store.Tx.
+	txMu sync.Mutex
+	// wal is set by OpenStore, walSequence comes from the loaded dump.
+	wal         *writeAheadLog
+	walSequence uint64
+	// feed delivers changes of all collections to watchers, see Store.Watch.
+	feed *changeFeed
+	// The reaper removes expired documents every reapInterval, it is
+	// started by the first collection with expiry and stopped by Close.
+	reapInterval time.Duration
+	reaperStop   chan struct{}
+	reaperDone   chan struct{}
+	closed       bool
 }
 
 func NewStore() *Store {
 	pkgLogger.Info("initializing store")
 	return &Store{
-		collections: make(map[string]*Collection),
+		collections:  make(map[string]*Collection),
+		feed:         newChangeFeed(),
+		reapInterval: defaultReapInterval,
 	}
 }
 
@@ -53,11 +69,20 @@ func NewStore() *Store {
 // The dump structure is going to be used for serialization.
 type dumpCollection struct {
 	Config    CollectionConfig `json:"config"`
+	Schema    *Schema          `json:"schema,omitempty"`
 	Documents []Document       `json:"documents"`
+	// Last revision assigned in the collection, so revisions keep growing after restore.
+	Revision uint64 `json:"revision,omitempty"`
+	// Last key generated by KeyStrategySequence.
+	KeySequence uint64 `json:"keySequence,omitempty"`
 }
 
 type dumpStore struct {
 	Collections map[string]dumpCollection `json:"collections"`
+	// Last write-ahead log record included in the dump, set by Store.Checkpoint.
+	WALSequence uint64 `json:"walSequence,omitempty"`
+	// Checksum of the rest of the dump, verified by NewStoreFromDump.
+	Checksum string `json:"checksum,omitempty"`
 }
 
 func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (*Collection, error) {
@@ -68,6 +93,30 @@ func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (*Collectio
 		pkgLogger.Error("[Store] Error: invalid collection name or config", slog.String("name", name))
 		return nil, ErrCollectionInvalidNameOrKey
 	}
+	if err := validateIndexes(cfg.Indexes); err != nil {
+		pkgLogger.Error("[Store] Error: invalid collection indexes", slog.String("name", name), slog.Any("error", err))
+		return nil, err
+	}
+	if err := validateUniqueConstraints(cfg.Unique); err != nil {
+		pkgLogger.Error("[Store] Error: invalid unique constraints", slog.String("name", name), slog.Any("error", err))
+		return nil, err
+	}
+	if err := validateExpiry(cfg); err != nil {
+		pkgLogger.Error("[Store] Error: invalid expiry", slog.String("name", name), slog.Any("error", err))
+		return nil, err
+	}
+	if err := validateKeyStrategy(cfg.KeyStrategy); err != nil {
+		pkgLogger.Error("[Store] Error: invalid key strategy", slog.String("name", name), slog.Any("error", err))
+		return nil, err
+	}
+	if err := validateCap(cfg); err != nil {
+		pkgLogger.Error("[Store] Error: invalid collection limits", slog.String("name", name), slog.Any("error", err))
+		return nil, err
+	}
+	if _, err := compileSchema(cfg.Schema); err != nil {
+		pkgLogger.Error("[Store] Error: invalid collection schema", slog.String("name", name), slog.Any("error", err))
+		return nil, err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if _, exists := s.collections[name]; exists {
@@ -76,9 +125,24 @@ func (s *Store) CreateCollection(name string, cfg *CollectionConfig) (*Collectio
 	}
 
 	collection := NewCollection(cfg)
+	collection.name = name
+	collection.wal = s.wal
+	collection.feed = s.feed
+	collection.store = s
+	if s.wal != nil {
+		walCfg := collection.cfg
+		if err := s.wal.append(walRecord{Op: walOpCreateCollection, Collection: name, Config: &walCfg, Schema: walCfg.Schema}); err != nil {
+			pkgLogger.Error("[Store] Error: failed to log collection creation", slog.String("name", name), slog.Any("error", err))
+			return nil, err
+		}
+	}
 	pkgLogger.Info("collection created", slog.String("name", name), slog.String("primaryKey", cfg.PrimaryKey))
 
 	s.collections[name] = collection
+	if collection.cfg.ExpireField != "" {
+		s.startReaperLocked()
+	}
+	s.feed.publish(ChangeEvent{Type: ChangeCreate, Collection: name, source: collection})
 	return collection, nil
 }
 
@@ -106,9 +170,21 @@ func (s *Store) DeleteCollection(name string) error {
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, exists := s.collections[name]; exists {
+	if coll, exists := s.collections[name]; exists {
+		if s.wal != nil {
+			if err := s.wal.append(walRecord{Op: walOpDeleteCollection, Collection: name}); err != nil {
+				pkgLogger.Error("[Store DeleteCollection Delete] failed to log collection deletion", slog.String("name", name), slog.Any("error", err))
+				return err
+			}
+		}
 		pkgLogger.Info("[Store DeleteCollection Delete] deleting collection", slog.String("name", name))
 		delete(s.collections, name)
+		// handles to the collection may outlive it, they must not log writes
+		// that replay would apply to another collection with the same name
+		coll.mu.Lock()
+		coll.wal, coll.store, coll.dropped = nil, nil, true
+		coll.mu.Unlock()
+		s.feed.publish(ChangeEvent{Type: ChangeDrop, Collection: name, source: coll})
 		return nil
 	}
 	pkgLogger.Error("[Store DeleteCollection Delete] collection doesn't exist", slog.String("name", name))
@@ -131,19 +207,27 @@ func NewStoreFromDump(dump []byte) (*Store, error) {
 		pkgLogger.Error("failed to unmarshal dump", slog.Any("error", err))
 		return nil, err
 	}
+	if err := verifyDumpChecksum(dump, ds.Checksum); err != nil {
+		pkgLogger.Error("dump checksum verification failed", slog.Any("error", err))
+		return nil, err
+	}
 	store := NewStore()
+	store.walSequence = ds.WALSequence
 	for name, collDump := range ds.Collections {
+		collDump.Config.Schema = collDump.Schema
 		collection, err := store.CreateCollection(name, &collDump.Config)
 		if err != nil {
 			pkgLogger.Error("failed to create collection from dump", slog.String("name", name), slog.Any("error", err))
 			return nil, fmt.Errorf("failed to create collection '%s': %w", name, err)
 		}
 		for _, doc := range collDump.Documents {
-			if collection.Put(doc) != nil {
+			if err := collection.restore(doc); err != nil {
 				pkgLogger.Error("failed to put document into collection from dump", slog.String("collection", name), slog.Any("document", doc))
-				return nil, fmt.Errorf("failed to put document into collection '%s' from dump", name)
+				return nil, fmt.Errorf("failed to put document into collection '%s' from dump: %w", name, err)
 			}
 		}
+		collection.revision = max(collection.revision, collDump.Revision)
+		collection.keySequence = collDump.KeySequence
 		pkgLogger.Info("loaded collection from dump", slog.String("name", name), slog.Int("documents", len(collDump.Documents)))
 	}
 	pkgLogger.Info("store initialized from dump", slog.Int("collections", len(store.collections)))
@@ -161,23 +245,49 @@ func (s *Store) Dump() ([]byte, error) {
 	for name, coll := range s.collections {
 		// Read collection content under its read lock
 		coll.mu.RLock()
-		docs := make([]Document, 0, len(coll.documents))
-		for _, doc := range coll.documents {
+		dc := coll.dumpLocked()
+		coll.mu.RUnlock()
+		ds.Collections[name] = dc
+		pkgLogger.Info("prepared collection for dump", slog.String("name", name), slog.Int("documents", len(dc.Documents)))
+	}
+	return marshalDump(ds)
+}
+
+// dumpLocked copies the collection state for a dump.
+// Must be called with the collection lock held.
+func (c *Collection) dumpLocked() dumpCollection {
+	docs := make([]Document, 0, len(c.documents))
+	if c.capped != nil {
+		// insertion order decides what a restored collection evicts first
+		for elem := c.capped.order.Front(); elem != nil; elem = elem.Next() {
+			docs = append(docs, *c.documents[elem.Value.(string)])
+		}
+	} else {
+		for _, doc := range c.documents {
 			if doc != nil {
 				docs = append(docs, *doc)
 			}
 		}
-		cfg := coll.cfg
-		coll.mu.RUnlock()
-		ds.Collections[name] = dumpCollection{Config: cfg, Documents: docs}
-		pkgLogger.Info("prepared collection for dump", slog.String("name", name), slog.Int("documents", len(docs)))
+	}
+	return dumpCollection{Config: c.cfg, Schema: c.cfg.Schema, Documents: docs, Revision: c.revision, KeySequence: c.keySequence}
+}
+
+func marshalDump(ds dumpStore) ([]byte, error) {
+	ds.Checksum = ""
+	compact, err := json.Marshal(ds)
+	if err != nil {
+		pkgLogger.Error("failed to marshal store dump", slog.Any("error", err))
+		return nil, fmt.Errorf("failed to marshal store dump: %w", err)
+	}
+	if ds.Checksum, err = dumpChecksum(compact); err != nil {
+		return nil, err
 	}
 	data, err := json.MarshalIndent(ds, "", "  ")
 	if err != nil {
 		pkgLogger.Error("failed to marshal store dump", slog.Any("error", err))
 		return nil, fmt.Errorf("failed to marshal store dump: %w", err)
 	}
-	pkgLogger.Info("store dump generated", slog.Int("bytes", len(data)), slog.Int("collections", len(s.collections)))
+	pkgLogger.Info("store dump generated", slog.Int("bytes", len(data)), slog.Int("collections", len(ds.Collections)))
 	return data, nil
 }
 
@@ -202,22 +312,25 @@ func NewStoreFromFile(filename string) (*Store, error) {
 
 func (s *Store) DumpToFile(filename string) error {
 	// Робить те ж саме що і метод  `Dump`, але записує у файл замість того щоб повертати сам дамп
-	// TODO: Implement
-	// https://pkg.go.dev/os@go1.25.5#WriteFile
+	return s.DumpToFileWithOptions(filename, DumpOptions{})
+}
+
+// DumpToFileWithOptions atomically replaces filename with a new dump,
+// optionally keeping previous dumps, see DumpOptions.
+func (s *Store) DumpToFileWithOptions(filename string, opts DumpOptions) error {
 	filename = strings.TrimSpace(filename)
 	if filename == "" {
 		pkgLogger.Error("filename is empty")
 		return ErrCollectionFileName
 	}
+	if opts.Keep < 0 {
+		pkgLogger.Error("invalid number of dumps to keep", slog.Int("keep", opts.Keep))
+		return ErrDumpOptions
+	}
 	dump, err := s.Dump()
 	if err != nil {
 		pkgLogger.Error("failed to generate dump", slog.Any("error", err))
 		return err
 	}
-	if err := os.WriteFile(filename, dump, 0644); err != nil {
-		pkgLogger.Error("failed to write dump to file", slog.String("file", filename), slog.Any("error", err))
-		return err
-	}
-	pkgLogger.Info("dump written to file", slog.String("file", filename), slog.Int("bytes", len(dump)))
-	return nil
+	return writeDumpFile(filename, dump, opts.Keep)
 }
@@ -0,0 +1,289 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInvalidDocTag = errors.New("invalid doc tag")
+)
+
+// Struct fields are mapped to document fields by the `doc` tag, falling back
+// to the `json` tag and the Go field name:
+//
+//	doc:"name,omitempty,string,pk,index,unique"
+//
+//   - name       document field name, "-" skips the field
+//   - omitempty  the field is not stored when it has a zero value
+//   - string     numbers and bools are stored as strings (like json ",string")
+//   - pk         the field is the primary key of the collection
+//   - index      the collection gets a hash index on the field, index=ordered for an ordered one
+//   - unique     the collection gets a unique constraint on the field
+//
+// Fields of embedded structs without a name are flattened into the parent,
+// following the encoding/json rules for conflicting names.
+
+type structField struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+	asString  bool
+	pk        bool
+	indexType IndexType
+	unique    bool
+	// depth and tagged resolve conflicts of flattened fields
+	depth  int
+	tagged bool
+}
+
+type fieldTag struct {
+	name      string
+	skip      bool
+	omitEmpty bool
+	asString  bool
+	pk        bool
+	indexType IndexType
+	unique    bool
+}
+
+func parseFieldTag(sf reflect.StructField) (fieldTag, error) {
+	var tag fieldTag
+	for _, key := range []string{"json", "doc"} {
+		raw, ok := sf.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(raw, ",")
+		switch name := strings.TrimSpace(parts[0]); {
+		case name == "-" && len(parts) == 1:
+			tag.skip = true
+		case name != "":
+			tag.name = name
+			tag.skip = false
+		}
+		for _, opt := range parts[1:] {
+			opt = strings.TrimSpace(opt)
+			switch {
+			case opt == "omitempty":
+				tag.omitEmpty = true
+			case opt == "string":
+				tag.asString = true
+			case key != "doc", opt == "":
+				// other json options are ignored
+			case opt == "pk":
+				tag.pk = true
+			case opt == "unique":
+				tag.unique = true
+			case opt == "index":
+				tag.indexType = IndexTypeHash
+			case strings.HasPrefix(opt, "index="):
+				tag.indexType = IndexType(strings.TrimPrefix(opt, "index="))
+				if tag.indexType != IndexTypeHash && tag.indexType != IndexTypeOrdered {
+					return tag, fmt.Errorf("%w: field %s: unknown index type %q", ErrInvalidDocTag, sf.Name, tag.indexType)
+				}
+			default:
+				return tag, fmt.Errorf("%w: field %s: unknown option %q", ErrInvalidDocTag, sf.Name, opt)
+			}
+		}
+	}
+	return tag, nil
+}
+
+type structFieldsResult struct {
+	fields []structField
+	err    error
+}
+
+var structFieldsCache sync.Map // map[reflect.Type]structFieldsResult
+
+// structFields returns the document fields of a struct type, cached per type.
+func structFields(typ reflect.Type) ([]structField, error) {
+	if cached, ok := structFieldsCache.Load(typ); ok {
+		res := cached.(structFieldsResult)
+		return res.fields, res.err
+	}
+	fields, err := typeFields(typ)
+	structFieldsCache.Store(typ, structFieldsResult{fields: fields, err: err})
+	return fields, err
+}
+
+func typeFields(typ reflect.Type) ([]structField, error) {
+	type level struct {
+		typ   reflect.Type
+		index []int
+	}
+	var fields []structField
+	visited := map[reflect.Type]bool{}
+	current := []level{{typ: typ}}
+	for depth := 0; len(current) > 0; depth++ {
+		var next []level
+		for _, l := range current {
+			if visited[l.typ] {
+				continue
+			}
+			visited[l.typ] = true
+			for i := 0; i < l.typ.NumField(); i++ {
+				sf := l.typ.Field(i)
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if !sf.IsExported() && !(sf.Anonymous && ft.Kind() == reflect.Struct) {
+					continue
+				}
+				tag, err := parseFieldTag(sf)
+				if err != nil {
+					return nil, err
+				}
+				if tag.skip {
+					continue
+				}
+				index := append(append([]int(nil), l.index...), i)
+				if sf.Anonymous && ft.Kind() == reflect.Struct && tag.name == "" {
+					next = append(next, level{typ: ft, index: index})
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				name := tag.name
+				if name == "" {
+					name = sf.Name
+				}
+				fields = append(fields, structField{
+					name:      name,
+					index:     index,
+					typ:       sf.Type,
+					omitEmpty: tag.omitEmpty,
+					asString:  tag.asString,
+					pk:        tag.pk,
+					indexType: tag.indexType,
+					unique:    tag.unique,
+					depth:     depth,
+					tagged:    tag.name != "",
+				})
+			}
+		}
+		current = next
+	}
+	return dominantFields(fields), nil
+}
+
+// dominantFields keeps one field per name: the shallowest one, or the only
+// tagged one among equally deep fields. Ambiguous names are dropped.
+func dominantFields(fields []structField) []structField {
+	byName := make(map[string][]structField)
+	for _, f := range fields {
+		byName[f.name] = append(byName[f.name], f)
+	}
+	out := make([]structField, 0, len(byName))
+	for _, candidates := range byName {
+		minDepth := candidates[0].depth
+		for _, f := range candidates {
+			minDepth = min(minDepth, f.depth)
+		}
+		var shallow, tagged []structField
+		for _, f := range candidates {
+			if f.depth == minDepth {
+				shallow = append(shallow, f)
+				if f.tagged {
+					tagged = append(tagged, f)
+				}
+			}
+		}
+		switch {
+		case len(shallow) == 1:
+			out = append(out, shallow[0])
+		case len(tagged) == 1:
+			out = append(out, tagged[0])
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].index, out[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return out
+}
+
+// fieldByIndex returns the struct field, ok is false when an embedded pointer
+// on the way is nil. With alloc nil pointers are allocated instead.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports zero values skipped by omitempty, as in encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// CollectionConfigFor builds a collection config from the doc tags of a
+// struct type: the pk field becomes the primary key, index and unique
+// options become indexes and unique constraints.
+func CollectionConfigFor[T any]() (*CollectionConfig, error) {
+	return collectionConfigFor(reflect.TypeFor[T]())
+}
+
+func collectionConfigFor(typ reflect.Type) (*CollectionConfig, error) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, ErrDocumentInputIsNotStruct
+	}
+	fields, err := structFields(typ)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &CollectionConfig{}
+	for _, f := range fields {
+		if f.pk {
+			if cfg.PrimaryKey != "" {
+				return nil, fmt.Errorf("%w: %s has several pk fields", ErrTypedPrimaryKey, typ)
+			}
+			if f.typ.Kind() != reflect.String || f.asString {
+				return nil, fmt.Errorf("%w: %s.%s must be a string", ErrTypedPrimaryKey, typ, f.name)
+			}
+			cfg.PrimaryKey = f.name
+		}
+		if f.indexType != "" {
+			cfg.Indexes = append(cfg.Indexes, IndexConfig{Field: f.name, Type: f.indexType})
+		}
+		if f.unique {
+			cfg.Unique = append(cfg.Unique, UniqueConstraint{Fields: []string{f.name}})
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,124 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Audit struct {
+	CreatedBy string `doc:"createdBy"`
+	Version   int
+}
+
+type Meta struct {
+	Source   string `json:"source"`
+	Revision int    `json:"Version"`
+}
+
+type taggedAccount struct {
+	Audit
+	*Meta
+	ID      string  `json:"id" doc:"key,pk"`
+	Email   string  `doc:"email,unique"`
+	Balance float64 `doc:"balance,index=ordered"`
+	Tenant  string  `doc:"tenant,index"`
+	Note    string  `json:"note" doc:",omitempty"`
+	Limit   int64   `json:"limit,string"`
+	Active  bool    `doc:"active,string"`
+	Secret  string  `json:"secret" doc:"-"`
+}
+
+func TestMarshalDocument_Tags(t *testing.T) {
+	acc := taggedAccount{
+		Audit:   Audit{CreatedBy: "admin", Version: 2},
+		ID:      "a1",
+		Email:   "a@example.com",
+		Balance: 10.5,
+		Limit:   100,
+		Active:  true,
+		Secret:  "hidden",
+	}
+	doc, err := MarshalDocument(acc)
+	require.NoError(t, err)
+
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeString, Value: "a1"}, doc.Fields["key"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeString, Value: "admin"}, doc.Fields["createdBy"])
+	// the tagged Meta.Revision shadows Audit.Version even when Meta is nil
+	assert.NotContains(t, doc.Fields, "Version")
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeString, Value: "100"}, doc.Fields["limit"])
+	assert.Equal(t, DocumentField{Type: DocumentFieldTypeString, Value: "true"}, doc.Fields["active"])
+	for _, name := range []string{"id", "note", "secret", "Secret", "Audit", "Meta", "source"} {
+		assert.NotContains(t, doc.Fields, name)
+	}
+
+	acc.Meta = &Meta{Source: "import", Revision: 7}
+	acc.Note = "vip"
+	doc, err = MarshalDocument(acc)
+	require.NoError(t, err)
+	assert.Equal(t, "import", doc.Fields["source"].Value)
+	assert.Equal(t, "vip", doc.Fields["note"].Value)
+	assert.Equal(t, 7, doc.Fields["Version"].Value)
+
+	var out taggedAccount
+	require.NoError(t, UnmarshalDocument(doc, &out, Strict()))
+	acc.Secret = ""
+	acc.Audit.Version = 0
+	assert.Equal(t, acc, out)
+}
+
+func TestMarshalDocument_TagErrors(t *testing.T) {
+	type badOption struct {
+		ID string `doc:"id,primary"`
+	}
+	type badIndex struct {
+		ID string `doc:"id,index=btree"`
+	}
+	_, err := MarshalDocument(badOption{})
+	assert.ErrorIs(t, err, ErrInvalidDocTag)
+	_, err = MarshalDocument(badIndex{})
+	assert.ErrorIs(t, err, ErrInvalidDocTag)
+	assert.ErrorIs(t, UnmarshalDocument(&Document{Fields: map[string]DocumentField{}}, &badOption{}), ErrInvalidDocTag)
+}
+
+func TestUnmarshalDocument_StringOption(t *testing.T) {
+	doc := &Document{Fields: map[string]DocumentField{
+		"key":    {Type: DocumentFieldTypeString, Value: "a1"},
+		"limit":  {Type: DocumentFieldTypeString, Value: "many"},
+		"active": {Type: DocumentFieldTypeString, Value: "false"},
+	}}
+	var out taggedAccount
+	err := UnmarshalDocument(doc, &out, StrictTypes())
+	var uerr *UnmarshalError
+	require.ErrorAs(t, err, &uerr)
+	require.Len(t, uerr.Fields, 1)
+	assert.Equal(t, "limit", uerr.Fields[0].Path)
+	assert.Equal(t, "a1", out.ID)
+}
+
+func TestCollectionConfigFor(t *testing.T) {
+	cfg, err := CollectionConfigFor[taggedAccount]()
+	require.NoError(t, err)
+	assert.Equal(t, "key", cfg.PrimaryKey)
+	assert.Equal(t, []IndexConfig{
+		{Field: "balance", Type: IndexTypeOrdered},
+		{Field: "tenant", Type: IndexTypeHash},
+	}, cfg.Indexes)
+	assert.Equal(t, []UniqueConstraint{{Fields: []string{"email"}}}, cfg.Unique)
+
+	s := NewStore()
+	accounts, err := CreateTypedCollection[taggedAccount](s, "accounts", &CollectionConfig{
+		Indexes: []IndexConfig{{Field: "tenant", Type: IndexTypeOrdered}},
+	})
+	require.NoError(t, err)
+	got := accounts.Collection().cfg
+	assert.Equal(t, []IndexConfig{
+		{Field: "tenant", Type: IndexTypeOrdered},
+		{Field: "balance", Type: IndexTypeOrdered},
+	}, got.Indexes)
+
+	require.NoError(t, accounts.Put(taggedAccount{ID: "a1", Email: "x@example.com"}))
+	err = accounts.Put(taggedAccount{ID: "a2", Email: "x@example.com"})
+	assert.ErrorIs(t, err, ErrUniqueViolation)
+}
@@ -0,0 +1,330 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrTxClosed = errors.New("[Store Tx] Error: transaction is already finished")
+	ErrTxNoFunc = errors.New("[Store Tx] Error: transaction function is nil")
+)
+
+// Tx gives access to several collections of a Store inside Store.Tx.
+//
+// A collection is locked exclusively the first time the transaction touches it
+// and stays locked until the transaction ends, so all reads see a consistent
+// snapshot and writes of other goroutines wait. Writes are buffered and
+// applied together on commit.
+//
+// The store read lock is held for the whole transaction, so collections are
+// resolved without taking it again while collection locks are held, and
+// CreateCollection and DeleteCollection wait until the transaction ends.
+//
+// Inside the transaction function collections must only be accessed through
+// the Tx, calling Collection or Store methods directly may deadlock.
+type Tx struct {
+	store       *Store
+	collections map[string]*Collection
+	// writes holds staged documents per collection and key, nil means deleted.
+	writes map[string]map[string]*Document
+	// updated marks staged documents that come from Update, for change events.
+	updated map[string]map[string]bool
+	done    bool
+}
+
+type txUndo struct {
+	coll    *Collection
+	key     string
+	prev    *Document
+	existed bool
+	// capSeq is the insertion sequence of prev in a capped collection,
+	// restored so a rolled back removal keeps its eviction order.
+	capSeq uint64
+}
+
+// Tx runs fn in a transaction. When fn returns nil all staged writes are
+// committed atomically, otherwise they are discarded and the error is returned.
+// Transactions of one Store run one at a time.
+func (s *Store) Tx(fn func(tx *Tx) error) (err error) {
+	if fn == nil {
+		return ErrTxNoFunc
+	}
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tx := &Tx{
+		store:       s,
+		collections: make(map[string]*Collection),
+		writes:      make(map[string]map[string]*Document),
+		updated:     make(map[string]map[string]bool),
+	}
+	defer tx.release()
+
+	if err := fn(tx); err != nil {
+		pkgLogger.Warn("[Store Tx] transaction rolled back", slog.Any("error", err))
+		return err
+	}
+	if err := tx.commit(); err != nil {
+		pkgLogger.Error("[Store Tx] commit failed, transaction rolled back", slog.Any("error", err))
+		return err
+	}
+	pkgLogger.Info("[Store Tx] transaction committed", slog.Int("collections", len(tx.writes)))
+	return nil
+}
+
+func (tx *Tx) release() {
+	tx.done = true
+	for _, coll := range tx.collections {
+		coll.mu.Unlock()
+	}
+	tx.collections = nil
+}
+
+// collection returns a collection locked for the rest of the transaction.
+func (tx *Tx) collection(name string) (*Collection, error) {
+	if tx.done {
+		return nil, ErrTxClosed
+	}
+	if coll, ok := tx.collections[name]; ok {
+		return coll, nil
+	}
+	if strings.TrimSpace(name) == "" {
+		return nil, ErrCollectionInvalidNameOrKey
+	}
+	coll, ok := tx.store.collections[name]
+	if !ok {
+		pkgLogger.Error("[Store Tx] collection not found", slog.String("name", name))
+		return nil, ErrCollectionNotFound
+	}
+	coll.mu.Lock()
+	tx.collections[name] = coll
+	return coll, nil
+}
+
+// lookup returns the document visible inside the transaction.
+func (tx *Tx) lookup(name string, coll *Collection, key string) (*Document, bool) {
+	if staged, ok := tx.writes[name][key]; ok {
+		return staged, staged != nil
+	}
+	doc, ok := coll.documents[key]
+	return doc, ok
+}
+
+func (tx *Tx) stage(name, key string, doc *Document) {
+	if tx.writes[name] == nil {
+		tx.writes[name] = make(map[string]*Document)
+	}
+	tx.writes[name][key] = doc
+	delete(tx.updated[name], key)
+}
+
+func (tx *Tx) Get(collection, key string) (*Document, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, ErrKeyEmpty
+	}
+	coll, err := tx.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := tx.lookup(collection, coll, key)
+	if !ok {
+		return nil, ErrDocumentNotFound
+	}
+	result := *doc
+	return &result, nil
+}
+
+func (tx *Tx) Put(collection string, doc Document) error {
+	coll, err := tx.collection(collection)
+	if err != nil {
+		return err
+	}
+	key, err := coll.documentKey(doc)
+	if err != nil {
+		return err
+	}
+	tx.stage(collection, key, &doc)
+	return nil
+}
+
+func (tx *Tx) Delete(collection, key string) error {
+	if strings.TrimSpace(key) == "" {
+		return ErrKeyEmpty
+	}
+	coll, err := tx.collection(collection)
+	if err != nil {
+		return err
+	}
+	if _, ok := tx.lookup(collection, coll, key); !ok {
+		return ErrDocumentNotFound
+	}
+	tx.stage(collection, key, nil)
+	return nil
+}
+
+func (tx *Tx) Update(collection, key string, ops ...UpdateOp) (*Document, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, ErrKeyEmpty
+	}
+	coll, err := tx.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if err := op.validate(coll.cfg.PrimaryKey); err != nil {
+			return nil, err
+		}
+	}
+	current, ok := tx.lookup(collection, coll, key)
+	if !ok {
+		return nil, ErrDocumentNotFound
+	}
+	updated := Document{Fields: make(map[string]DocumentField, len(current.Fields))}
+	for name, field := range current.Fields {
+		updated.Fields[name] = field
+	}
+	for _, op := range ops {
+		if err := applyUpdateOp(&updated, op); err != nil {
+			return nil, err
+		}
+	}
+	tx.stage(collection, key, &updated)
+	if tx.updated[collection] == nil {
+		tx.updated[collection] = make(map[string]bool)
+	}
+	tx.updated[collection][key] = true
+	result := updated
+	return &result, nil
+}
+
+// Find returns documents matching the filter, including staged writes.
+func (tx *Tx) Find(collection string, filter Filter) ([]Document, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return tx.list(collection, &filter)
+}
+
+// List returns all documents visible in the transaction ordered by primary key.
+func (tx *Tx) List(collection string) ([]Document, error) {
+	return tx.list(collection, nil)
+}
+
+func (tx *Tx) list(collection string, filter *Filter) ([]Document, error) {
+	coll, err := tx.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(coll.documents))
+	for key := range coll.documents {
+		if _, staged := tx.writes[collection][key]; !staged {
+			keys = append(keys, key)
+		}
+	}
+	for key, doc := range tx.writes[collection] {
+		if doc != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	docs := make([]Document, 0, len(keys))
+	for _, key := range keys {
+		doc, _ := tx.lookup(collection, coll, key)
+		if filter == nil || filter.Match(doc) {
+			docs = append(docs, *doc)
+		}
+	}
+	return docs, nil
+}
+
+// commit applies staged writes collection by collection and undoes the
+// applied ones if any write is rejected.
+func (tx *Tx) commit() error {
+	names := make([]string, 0, len(tx.writes))
+	for name := range tx.writes {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	// Records of all collections are written to the wal as one batch after
+	// the writes are applied, so a transaction is never replayed partially.
+	// Change events are published only when the commit succeeds.
+	batch := make([]walRecord, 0)
+	events := make([]ChangeEvent, 0)
+	undo := make([]txUndo, 0)
+	for _, name := range names {
+		tx.collections[name].walBatch = &batch
+		tx.collections[name].eventBatch = &events
+		tx.collections[name].undoBatch = &undo
+	}
+	defer func() {
+		for _, name := range names {
+			tx.collections[name].walBatch = nil
+			tx.collections[name].eventBatch = nil
+			tx.collections[name].undoBatch = nil
+		}
+	}()
+
+	rollback := func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			u := undo[i]
+			if u.existed {
+				_ = u.coll.storeLocked(u.key, u.prev)
+				if u.capSeq != 0 {
+					u.coll.capped.place(u.key, u.capSeq)
+				}
+			} else {
+				u.coll.removeLocked(u.key)
+			}
+		}
+	}
+
+	for _, name := range names {
+		coll := tx.collections[name]
+		keys := make([]string, 0, len(tx.writes[name]))
+		for key := range tx.writes[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prev, existed := coll.documents[key]
+			doc := tx.writes[name][key]
+			if doc == nil {
+				if existed {
+					seq := coll.capSeqLocked(key)
+					_ = coll.deleteLocked(key)
+					undo = append(undo, txUndo{coll: coll, key: key, prev: prev, existed: true, capSeq: seq})
+				}
+				continue
+			}
+			change := ChangeReplace
+			if tx.updated[name][key] {
+				change = ChangeUpdate
+			}
+			if err := coll.writeLocked(key, doc, change); err != nil {
+				rollback()
+				return fmt.Errorf("collection '%s', document '%s': %w", name, key, err)
+			}
+			undo = append(undo, txUndo{coll: coll, key: key, prev: prev, existed: existed})
+		}
+	}
+	if wal := tx.collections[names[0]].wal; wal != nil && len(batch) > 0 {
+		if err := wal.append(walRecord{Op: walOpTx, Records: batch}); err != nil {
+			rollback()
+			return err
+		}
+	}
+	for _, ev := range events {
+		tx.store.feed.publish(ev)
+	}
+	return nil
+}
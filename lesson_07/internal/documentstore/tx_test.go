@@ -0,0 +1,223 @@
+package documentstore
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTxStore(t *testing.T) *Store {
+	t.Helper()
+	s := NewStore()
+	orders, err := s.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	_, err = s.CreateCollection("items", &CollectionConfig{
+		PrimaryKey: "id",
+		Unique:     []UniqueConstraint{{Fields: []string{"sku"}}},
+	})
+	require.NoError(t, err)
+	_, err = s.CreateCollection("archive", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, orders.Put(Document{Fields: map[string]DocumentField{
+		"id":    {Type: DocumentFieldTypeString, Value: "o1"},
+		"total": {Type: DocumentFieldTypeNumber, Value: 10},
+	}}))
+	return s
+}
+
+func itemDoc(id, sku string) Document {
+	return Document{Fields: map[string]DocumentField{
+		"id":  {Type: DocumentFieldTypeString, Value: id},
+		"sku": {Type: DocumentFieldTypeString, Value: sku},
+	}}
+}
+
+func TestStoreTx_Commit(t *testing.T) {
+	s := newTxStore(t)
+
+	err := s.Tx(func(tx *Tx) error {
+		order, err := tx.Get("orders", "o1")
+		if err != nil {
+			return err
+		}
+		// move the order to the archive
+		if err := tx.Put("archive", *order); err != nil {
+			return err
+		}
+		if err := tx.Delete("orders", "o1"); err != nil {
+			return err
+		}
+		if err := tx.Put("items", itemDoc("i1", "sku-1")); err != nil {
+			return err
+		}
+		if _, err := tx.Update("items", "i1", Set("qty", 2)); err != nil {
+			return err
+		}
+
+		// reads inside the transaction see staged writes
+		_, err = tx.Get("orders", "o1")
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		archived, err := tx.List("archive")
+		assert.NoError(t, err)
+		assert.Len(t, archived, 1)
+		found, err := tx.Find("items", Eq("qty", 2))
+		assert.NoError(t, err)
+		assert.Len(t, found, 1)
+		return nil
+	})
+	require.NoError(t, err)
+
+	orders, _ := s.GetCollection("orders")
+	archive, _ := s.GetCollection("archive")
+	items, _ := s.GetCollection("items")
+	assert.Empty(t, orders.List())
+	assert.Len(t, archive.List(), 1)
+	item, err := items.Get("i1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, item.Fields["qty"].Value)
+}
+
+func TestStoreTx_RollbackOnError(t *testing.T) {
+	s := newTxStore(t)
+	errStop := errors.New("stop")
+
+	err := s.Tx(func(tx *Tx) error {
+		if err := tx.Delete("orders", "o1"); err != nil {
+			return err
+		}
+		if err := tx.Put("items", itemDoc("i1", "sku-1")); err != nil {
+			return err
+		}
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+
+	orders, _ := s.GetCollection("orders")
+	items, _ := s.GetCollection("items")
+	assert.Len(t, orders.List(), 1)
+	assert.Empty(t, items.List())
+}
+
+func TestStoreTx_RollbackOnCommitFailure(t *testing.T) {
+	s := newTxStore(t)
+	items, _ := s.GetCollection("items")
+	require.NoError(t, items.Put(itemDoc("i0", "sku-taken")))
+
+	err := s.Tx(func(tx *Tx) error {
+		if err := tx.Delete("orders", "o1"); err != nil {
+			return err
+		}
+		if err := tx.Put("items", itemDoc("i1", "sku-free")); err != nil {
+			return err
+		}
+		return tx.Put("items", itemDoc("i2", "sku-taken"))
+	})
+	assert.ErrorIs(t, err, ErrUniqueViolation)
+
+	orders, _ := s.GetCollection("orders")
+	assert.Len(t, orders.List(), 1)
+	assert.Equal(t, []string{"i0"}, ids(items.List()))
+	// the unique value of the rolled back document is released
+	require.NoError(t, items.Put(itemDoc("i3", "sku-free")))
+}
+
+func TestStoreTx_Errors(t *testing.T) {
+	s := newTxStore(t)
+	assert.ErrorIs(t, s.Tx(nil), ErrTxNoFunc)
+
+	var leaked *Tx
+	err := s.Tx(func(tx *Tx) error {
+		leaked = tx
+		_, err := tx.Get("missing", "x")
+		return err
+	})
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+
+	_, err = leaked.Get("orders", "o1")
+	assert.ErrorIs(t, err, ErrTxClosed)
+}
+
+func TestStoreTx_SnapshotIsolation(t *testing.T) {
+	s := newTxStore(t)
+	orders, _ := s.GetCollection("orders")
+
+	started := make(chan struct{})
+	writerDone := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-started
+		_, err := orders.Update("o1", Inc("total", 5))
+		assert.NoError(t, err)
+		close(writerDone)
+	}()
+
+	err := s.Tx(func(tx *Tx) error {
+		first, err := tx.Get("orders", "o1")
+		if err != nil {
+			return err
+		}
+		close(started)
+		second, err := tx.Get("orders", "o1")
+		if err != nil {
+			return err
+		}
+		assert.Equal(t, first.Fields["total"].Value, second.Fields["total"].Value)
+		select {
+		case <-writerDone:
+			t.Error("concurrent write finished inside transaction")
+		default:
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	wg.Wait()
+
+	order, _ := orders.Get("o1")
+	assert.Equal(t, 15, order.Fields["total"].Value)
+}
+
+func TestStoreTx_ConcurrentDumpAndCreate(t *testing.T) {
+	s := newTxStore(t)
+
+	var wg sync.WaitGroup
+	err := s.Tx(func(tx *Tx) error {
+		if _, err := tx.Get("orders", "o1"); err != nil {
+			return err
+		}
+		// Dump waits for the orders lock, CreateCollection for the store lock
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := s.Dump()
+			assert.NoError(t, err)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		go func() {
+			defer wg.Done()
+			_, err := s.CreateCollection("late", &CollectionConfig{PrimaryKey: "id"})
+			assert.NoError(t, err)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		return tx.Put("archive", itemDoc("a1", "sku-1"))
+	})
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dump or create collection did not finish after the transaction")
+	}
+	_, err = s.GetCollection("late")
+	assert.NoError(t, err)
+}
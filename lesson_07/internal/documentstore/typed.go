@@ -0,0 +1,168 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+var (
+	ErrTypedPrimaryKey = errors.New("[TypedCollection] Error: invalid primary key field")
+)
+
+// TypedCollection stores Go structs of type T in a Collection, converting them
+// with MarshalDocument and UnmarshalDocument.
+//
+// The primary key is the string field tagged `doc:",pk"`:
+//
+//	type User struct {
+//		ID    string `doc:"id,pk"`
+//		Email string `doc:"email,unique"`
+//	}
+type TypedCollection[T any] struct {
+	coll *Collection
+}
+
+// TypedListResult is a page of ListWithOptions with decoded items.
+type TypedListResult[T any] struct {
+	Items      []T
+	NextCursor string
+}
+
+// NewTypedCollection wraps an existing collection. If T declares a primary key
+// it must match the primary key of the collection.
+func NewTypedCollection[T any](coll *Collection) (*TypedCollection[T], error) {
+	if coll == nil {
+		return nil, ErrCollectionNotFound
+	}
+	hints, err := CollectionConfigFor[T]()
+	if err != nil {
+		return nil, err
+	}
+	if hints.PrimaryKey != "" && hints.PrimaryKey != coll.cfg.PrimaryKey {
+		return nil, fmt.Errorf("%w: %s declares '%s', collection uses '%s'",
+			ErrTypedPrimaryKey, reflect.TypeFor[T](), hints.PrimaryKey, coll.cfg.PrimaryKey)
+	}
+	return &TypedCollection[T]{coll: coll}, nil
+}
+
+// CreateTypedCollection creates a collection in the store configured by the
+// doc tags of T: the primary key is taken from the pk field, index and unique
+// hints are added to cfg unless it already declares them. cfg may be nil.
+func CreateTypedCollection[T any](s *Store, name string, cfg *CollectionConfig) (*TypedCollection[T], error) {
+	hints, err := CollectionConfigFor[T]()
+	if err != nil {
+		return nil, err
+	}
+	if hints.PrimaryKey == "" {
+		return nil, fmt.Errorf("%w: %s has no field tagged doc:\",pk\"", ErrTypedPrimaryKey, reflect.TypeFor[T]())
+	}
+	var config CollectionConfig
+	if cfg != nil {
+		config = *cfg
+		config.Indexes = append([]IndexConfig(nil), cfg.Indexes...)
+		config.Unique = append([]UniqueConstraint(nil), cfg.Unique...)
+	}
+	if config.PrimaryKey != "" && config.PrimaryKey != hints.PrimaryKey {
+		return nil, fmt.Errorf("%w: %s declares '%s', config uses '%s'",
+			ErrTypedPrimaryKey, reflect.TypeFor[T](), hints.PrimaryKey, config.PrimaryKey)
+	}
+	config.PrimaryKey = hints.PrimaryKey
+	for _, ic := range hints.Indexes {
+		if !slices.ContainsFunc(config.Indexes, func(c IndexConfig) bool { return c.Field == ic.Field }) {
+			config.Indexes = append(config.Indexes, ic)
+		}
+	}
+	for _, uc := range hints.Unique {
+		if !slices.ContainsFunc(config.Unique, func(c UniqueConstraint) bool { return slices.Equal(c.Fields, uc.Fields) }) {
+			config.Unique = append(config.Unique, uc)
+		}
+	}
+	coll, err := s.CreateCollection(name, &config)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedCollection[T]{coll: coll}, nil
+}
+
+// Collection returns the underlying untyped collection.
+func (s *TypedCollection[T]) Collection() *Collection {
+	return s.coll
+}
+
+func (s *TypedCollection[T]) Put(item T) error {
+	doc, err := MarshalDocument(item)
+	if err != nil {
+		return err
+	}
+	return s.coll.Put(*doc)
+}
+
+func (s *TypedCollection[T]) Get(key string) (T, error) {
+	var item T
+	doc, err := s.coll.Get(key)
+	if err != nil {
+		return item, err
+	}
+	return decodeTyped[T](doc)
+}
+
+func (s *TypedCollection[T]) Delete(key string) error {
+	return s.coll.Delete(key)
+}
+
+// List returns all items ordered by primary key.
+func (s *TypedCollection[T]) List() ([]T, error) {
+	return decodeTypedAll[T](s.coll.List())
+}
+
+func (s *TypedCollection[T]) Find(filter Filter) ([]T, error) {
+	docs, err := s.coll.Find(filter)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTypedAll[T](docs)
+}
+
+func (s *TypedCollection[T]) ListWithOptions(opts ListOptions) (*TypedListResult[T], error) {
+	res, err := s.coll.ListWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	items, err := decodeTypedAll[T](res.Documents)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedListResult[T]{Items: items, NextCursor: res.NextCursor}, nil
+}
+
+// Update applies update operators and returns the updated item.
+func (s *TypedCollection[T]) Update(key string, ops ...UpdateOp) (T, error) {
+	doc, err := s.coll.Update(key, ops...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decodeTyped[T](doc)
+}
+
+func decodeTyped[T any](doc *Document) (T, error) {
+	var item T
+	if err := UnmarshalDocument(doc, &item); err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+func decodeTypedAll[T any](docs []Document) ([]T, error) {
+	items := make([]T, 0, len(docs))
+	for i := range docs {
+		item, err := decodeTyped[T](&docs[i])
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,91 @@
+package documentstore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type typedUser struct {
+	ID   string   `json:"id" doc:",pk"`
+	Name string   `json:"name"`
+	Age  int      `json:"age"`
+	Tags []string `json:"tags"`
+}
+
+func TestTypedCollection(t *testing.T) {
+	s := NewStore()
+	users, err := CreateTypedCollection[typedUser](s, "users", &CollectionConfig{
+		Indexes: []IndexConfig{{Field: "age", Type: IndexTypeOrdered}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "id", users.Collection().cfg.PrimaryKey)
+
+	require.NoError(t, users.Put(typedUser{ID: "u2", Name: "bob", Age: 25}))
+	require.NoError(t, users.Put(typedUser{ID: "u1", Name: "alice", Age: 30, Tags: []string{"admin"}}))
+	require.NoError(t, users.Put(typedUser{ID: "u3", Name: "carol", Age: 41}))
+
+	got, err := users.Get("u1")
+	require.NoError(t, err)
+	assert.Equal(t, typedUser{ID: "u1", Name: "alice", Age: 30, Tags: []string{"admin"}}, got)
+
+	_, err = users.Get("missing")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+	all, err := users.List()
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "u1", all[0].ID)
+
+	found, err := users.Find(Gte("age", 30))
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+
+	page, err := users.ListWithOptions(ListOptions{Sort: []SortKey{{Field: "age", Order: SortDesc}}, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "carol", page.Items[0].Name)
+	assert.NotEmpty(t, page.NextCursor)
+
+	updated, err := users.Update("u2", Inc("age", 1))
+	require.NoError(t, err)
+	assert.Equal(t, 26, updated.Age)
+
+	require.NoError(t, users.Delete("u2"))
+	_, err = users.Get("u2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+}
+
+func TestTypedCollection_PrimaryKey(t *testing.T) {
+	type noKey struct {
+		Name string `json:"name"`
+	}
+	type intKey struct {
+		ID int `doc:",pk"`
+	}
+	type twoKeys struct {
+		A string `doc:",pk"`
+		B string `doc:",pk"`
+	}
+
+	s := NewStore()
+	_, err := CreateTypedCollection[noKey](s, "a", nil)
+	assert.ErrorIs(t, err, ErrTypedPrimaryKey)
+	_, err = CreateTypedCollection[intKey](s, "b", nil)
+	assert.ErrorIs(t, err, ErrTypedPrimaryKey)
+	_, err = CreateTypedCollection[twoKeys](s, "c", nil)
+	assert.ErrorIs(t, err, ErrTypedPrimaryKey)
+	_, err = CreateTypedCollection[typedUser](s, "d", &CollectionConfig{PrimaryKey: "name"})
+	assert.ErrorIs(t, err, ErrTypedPrimaryKey)
+	_, err = CreateTypedCollection[string](s, "e", nil)
+	assert.ErrorIs(t, err, ErrDocumentInputIsNotStruct)
+
+	coll, err := s.CreateCollection("keyed", &CollectionConfig{PrimaryKey: "key"})
+	require.NoError(t, err)
+	_, err = NewTypedCollection[typedUser](coll)
+	assert.ErrorIs(t, err, ErrTypedPrimaryKey)
+	// types without a pk tag rely on the collection config
+	_, err = NewTypedCollection[noKey](coll)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,108 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUniqueViolation         = errors.New("[Collection] Error: unique constraint violation")
+	ErrInvalidUniqueConstraint = errors.New("[Collection] Error: invalid unique constraint")
+)
+
+// UniqueConstraint requires the combination of Fields to be unique across
+// the collection. Documents that miss any of the fields are not checked.
+type UniqueConstraint struct {
+	Fields []string
+}
+
+// UniqueViolationError is returned by Put when a document conflicts with
+// another document on a unique constraint.
+type UniqueViolationError struct {
+	Fields      []string
+	ExistingKey string
+}
+
+func (e *UniqueViolationError) Error() string {
+	return fmt.Sprintf("%s: fields [%s] already used by document '%s'",
+		ErrUniqueViolation.Error(), strings.Join(e.Fields, ", "), e.ExistingKey)
+}
+
+func (e *UniqueViolationError) Is(target error) bool {
+	return target == ErrUniqueViolation
+}
+
+func validateUniqueConstraints(constraints []UniqueConstraint) error {
+	for _, uc := range constraints {
+		if len(uc.Fields) == 0 {
+			return fmt.Errorf("%w: no fields", ErrInvalidUniqueConstraint)
+		}
+		seen := make(map[string]bool, len(uc.Fields))
+		for _, field := range uc.Fields {
+			if strings.TrimSpace(field) == "" {
+				return fmt.Errorf("%w: empty field name", ErrInvalidUniqueConstraint)
+			}
+			if seen[field] {
+				return fmt.Errorf("%w: duplicate field %s", ErrInvalidUniqueConstraint, field)
+			}
+			seen[field] = true
+		}
+	}
+	return nil
+}
+
+// uniqueIndex maps the combined value of the constraint fields to the
+// primary key of the document that owns it.
+type uniqueIndex struct {
+	fields  []string
+	entries map[string]string
+}
+
+func newUniqueIndex(uc UniqueConstraint) *uniqueIndex {
+	return &uniqueIndex{
+		fields:  append([]string(nil), uc.Fields...),
+		entries: make(map[string]string),
+	}
+}
+
+// valueKey combines hash keys of all constraint fields.
+// ok is false when the document is not covered by the constraint.
+func (u *uniqueIndex) valueKey(doc *Document) (string, bool) {
+	parts := make([]string, 0, len(u.fields))
+	for _, name := range u.fields {
+		field, found := lookupField(doc, name)
+		if !found {
+			return "", false
+		}
+		hk, ok := hashKey(field)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, hk)
+	}
+	return strings.Join(parts, "\x00"), true
+}
+
+func (u *uniqueIndex) check(key string, doc *Document) error {
+	vk, ok := u.valueKey(doc)
+	if !ok {
+		return nil
+	}
+	if owner, exists := u.entries[vk]; exists && owner != key {
+		return &UniqueViolationError{Fields: append([]string(nil), u.fields...), ExistingKey: owner}
+	}
+	return nil
+}
+
+func (u *uniqueIndex) add(key string, doc *Document) {
+	if vk, ok := u.valueKey(doc); ok {
+		u.entries[vk] = key
+	}
+}
+
+func (u *uniqueIndex) remove(key string, doc *Document) {
+	if vk, ok := u.valueKey(doc); ok && u.entries[vk] == key {
+		delete(u.entries, vk)
+	}
+}
@@ -0,0 +1,110 @@
+package documentstore
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func userDoc(id, email, tenant string) Document {
+	return Document{Fields: map[string]DocumentField{
+		"id":     {Type: DocumentFieldTypeString, Value: id},
+		"email":  {Type: DocumentFieldTypeString, Value: email},
+		"tenant": {Type: DocumentFieldTypeString, Value: tenant},
+	}}
+}
+
+func TestUnique_SingleField(t *testing.T) {
+	col := NewCollection(&CollectionConfig{
+		PrimaryKey: "id",
+		Unique:     []UniqueConstraint{{Fields: []string{"email"}}},
+	})
+
+	require.NoError(t, col.Put(userDoc("u1", "a@example.com", "t1")))
+	// replacing the owner itself is fine
+	require.NoError(t, col.Put(userDoc("u1", "a@example.com", "t2")))
+
+	err := col.Put(userDoc("u2", "a@example.com", "t1"))
+	require.ErrorIs(t, err, ErrUniqueViolation)
+	var violation *UniqueViolationError
+	require.True(t, errors.As(err, &violation))
+	assert.Equal(t, []string{"email"}, violation.Fields)
+	assert.Equal(t, "u1", violation.ExistingKey)
+
+	_, err = col.Get("u2")
+	assert.ErrorIs(t, err, ErrDocumentNotFound)
+
+	// the value is released when the owner changes or is deleted
+	require.NoError(t, col.Put(userDoc("u1", "b@example.com", "t1")))
+	require.NoError(t, col.Put(userDoc("u2", "a@example.com", "t1")))
+	require.NoError(t, col.Delete("u2"))
+	require.NoError(t, col.Put(userDoc("u3", "a@example.com", "t1")))
+
+	// documents without the field are not constrained
+	noEmail := Document{Fields: map[string]DocumentField{"id": {Type: DocumentFieldTypeString, Value: "u4"}}}
+	noEmail2 := Document{Fields: map[string]DocumentField{"id": {Type: DocumentFieldTypeString, Value: "u5"}}}
+	require.NoError(t, col.Put(noEmail))
+	require.NoError(t, col.Put(noEmail2))
+}
+
+func TestUnique_Compound(t *testing.T) {
+	col := NewCollection(&CollectionConfig{
+		PrimaryKey: "id",
+		Unique:     []UniqueConstraint{{Fields: []string{"tenant", "email"}}},
+	})
+
+	require.NoError(t, col.Put(userDoc("u1", "a@example.com", "t1")))
+	require.NoError(t, col.Put(userDoc("u2", "a@example.com", "t2")))
+
+	err := col.Put(userDoc("u3", "a@example.com", "t2"))
+	var violation *UniqueViolationError
+	require.True(t, errors.As(err, &violation))
+	assert.Equal(t, []string{"tenant", "email"}, violation.Fields)
+	assert.Equal(t, "u2", violation.ExistingKey)
+}
+
+func TestUnique_PersistedThroughDump(t *testing.T) {
+	s := NewStore()
+	col, err := s.CreateCollection("users", &CollectionConfig{
+		PrimaryKey: "id",
+		Unique:     []UniqueConstraint{{Fields: []string{"email"}}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, col.Put(userDoc("u1", "a@example.com", "t1")))
+
+	data, err := s.Dump()
+	require.NoError(t, err)
+	s2, err := NewStoreFromDump(data)
+	require.NoError(t, err)
+	col2, err := s2.GetCollection("users")
+	require.NoError(t, err)
+	assert.ErrorIs(t, col2.Put(userDoc("u2", "a@example.com", "t1")), ErrUniqueViolation)
+
+	t.Run("dump violating constraint is rejected", func(t *testing.T) {
+		bad := dumpStore{Collections: map[string]dumpCollection{
+			"users": {
+				Config:    CollectionConfig{PrimaryKey: "id", Unique: []UniqueConstraint{{Fields: []string{"email"}}}},
+				Documents: []Document{userDoc("u1", "a@example.com", "t1"), userDoc("u2", "a@example.com", "t1")},
+			},
+		}}
+		data, _ := json.Marshal(bad)
+		_, err := NewStoreFromDump(data)
+		assert.ErrorIs(t, err, ErrUniqueViolation)
+	})
+}
+
+func TestCreateCollection_InvalidUnique(t *testing.T) {
+	s := NewStore()
+	invalid := [][]UniqueConstraint{
+		{{Fields: nil}},
+		{{Fields: []string{" "}}},
+		{{Fields: []string{"email", "email"}}},
+	}
+	for _, unique := range invalid {
+		_, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id", Unique: unique})
+		assert.ErrorIs(t, err, ErrInvalidUniqueConstraint)
+	}
+}
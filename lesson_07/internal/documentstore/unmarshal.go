@@ -0,0 +1,385 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnmarshalStrict = errors.New("unmarshal: document does not match struct")
+)
+
+type FieldErrorKind string
+
+const (
+	FieldErrorUnknown      FieldErrorKind = "unknown field"
+	FieldErrorMissing      FieldErrorKind = "missing field"
+	FieldErrorTypeMismatch FieldErrorKind = "type mismatch"
+	// FieldErrorInvalid is reported when a custom unmarshaler or parser rejects the value.
+	FieldErrorInvalid FieldErrorKind = "invalid value"
+)
+
+// FieldError describes one field that could not be decoded or validated.
+// UnmarshalDocument paths use dots for object fields and [i] for array
+// elements, e.g. "previous[1].city"; schema errors use JSON pointers.
+// Expected is the Go type of the struct field, Actual the type stored in the document.
+type FieldError struct {
+	Path     string
+	Kind     FieldErrorKind
+	Expected string
+	Actual   string
+}
+
+func (e FieldError) Error() string {
+	switch e.Kind {
+	case FieldErrorUnknown:
+		return fmt.Sprintf("%s: %s of type %s", e.Path, e.Kind, e.Actual)
+	case FieldErrorMissing:
+		return fmt.Sprintf("%s: %s of type %s", e.Path, e.Kind, e.Expected)
+	}
+	return fmt.Sprintf("%s: %s: expected %s, got %s", e.Path, e.Kind, e.Expected, e.Actual)
+}
+
+// UnmarshalError is returned by UnmarshalDocument in strict modes and lists
+// every offending field.
+type UnmarshalError struct {
+	Fields []FieldError
+}
+
+func (e *UnmarshalError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return fmt.Sprintf("%s: %s", ErrUnmarshalStrict.Error(), strings.Join(msgs, "; "))
+}
+
+func (e *UnmarshalError) Is(target error) bool {
+	return target == ErrUnmarshalStrict
+}
+
+type UnmarshalOption func(*unmarshalOptions)
+
+type unmarshalOptions struct {
+	disallowUnknown bool
+	requireFields   bool
+	strictTypes     bool
+}
+
+// DisallowUnknownFields reports document fields that have no struct field.
+func DisallowUnknownFields() UnmarshalOption {
+	return func(o *unmarshalOptions) { o.disallowUnknown = true }
+}
+
+// RequireFields reports struct fields missing in the document.
+// Fields tagged with json omitempty are optional.
+func RequireFields() UnmarshalOption {
+	return func(o *unmarshalOptions) { o.requireFields = true }
+}
+
+// StrictTypes reports fields whose type does not match the struct field
+// instead of skipping them. Numbers must also fit the target type exactly.
+func StrictTypes() UnmarshalOption {
+	return func(o *unmarshalOptions) { o.strictTypes = true }
+}
+
+// Strict enables all strict checks.
+func Strict() UnmarshalOption {
+	return func(o *unmarshalOptions) {
+		o.disallowUnknown = true
+		o.requireFields = true
+		o.strictTypes = true
+	}
+}
+
+// decoder rebuilds Go values from DocumentField trees and collects field errors.
+type decoder struct {
+	opts unmarshalOptions
+	errs []FieldError
+}
+
+func (d *decoder) fail(path string, kind FieldErrorKind, expected, actual string) {
+	d.errs = append(d.errs, FieldError{Path: path, Kind: kind, Expected: expected, Actual: actual})
+}
+
+// err returns the collected errors. Without StrictTypes mismatched and
+// invalid fields are skipped and only logged.
+func (d *decoder) err() error {
+	reported := make([]FieldError, 0, len(d.errs))
+	for _, e := range d.errs {
+		if (e.Kind == FieldErrorTypeMismatch || e.Kind == FieldErrorInvalid) && !d.opts.strictTypes {
+			pkgLogger.Warn("[UnmarshalDocument] field skipped", slog.String("field", e.Path), slog.String("error", e.Error()))
+			continue
+		}
+		reported = append(reported, e)
+	}
+	if len(reported) == 0 {
+		return nil
+	}
+	return &UnmarshalError{Fields: reported}
+}
+
+// decodeStruct fills out from a map of stored values keyed by field name.
+func (d *decoder) decodeStruct(path string, src reflect.Value, out reflect.Value) {
+	fields, err := structFields(out.Type())
+	if err != nil {
+		d.fail(path, FieldErrorTypeMismatch, out.Type().String(), err.Error())
+		return
+	}
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.name] = true
+		fieldPath := joinPath(path, f.name)
+		stored := src.MapIndex(reflect.ValueOf(f.name).Convert(src.Type().Key()))
+		if !stored.IsValid() {
+			if d.opts.requireFields && !f.omitEmpty {
+				d.fail(fieldPath, FieldErrorMissing, f.typ.String(), "")
+			}
+			continue
+		}
+		value := stored.Interface()
+		if f.asString {
+			unquoted, ok := d.unquote(fieldPath, value, f.typ)
+			if !ok {
+				continue
+			}
+			value = unquoted
+		}
+		decoded, ok := d.decode(fieldPath, value, f.typ)
+		if !ok {
+			continue
+		}
+		if target, ok := fieldByIndex(out, f.index, true); ok {
+			target.Set(decoded)
+		}
+	}
+	if d.opts.disallowUnknown {
+		iter := src.MapRange()
+		for iter.Next() {
+			if name := iter.Key().String(); !known[name] {
+				d.fail(joinPath(path, name), FieldErrorUnknown, "", storedTypeName(iter.Value().Interface()))
+			}
+		}
+	}
+}
+
+// unquote parses a string stored for a number or bool field tagged with the
+// string option. Values of other types are returned as is.
+func (d *decoder) unquote(path string, v any, typ reflect.Type) (any, bool) {
+	if f, ok := v.(DocumentField); ok {
+		v = f.Value
+	}
+	s, ok := v.(string)
+	if !ok {
+		return v, true
+	}
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	var parsed any
+	var err error
+	switch {
+	case typ.Kind() == reflect.Bool:
+		parsed, err = strconv.ParseBool(s)
+	case isIntKind(typ.Kind()):
+		parsed, err = strconv.ParseInt(s, 10, 64)
+	case isUintKind(typ.Kind()):
+		parsed, err = strconv.ParseUint(s, 10, 64)
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		parsed, err = strconv.ParseFloat(s, 64)
+	default:
+		return v, true
+	}
+	if err != nil {
+		d.fail(path, FieldErrorTypeMismatch, typ.String(), "string "+strconv.Quote(s))
+		return nil, false
+	}
+	return parsed, true
+}
+
+// decode converts a stored value, possibly a DocumentField tree, to typ.
+// Nested structs, maps, slices and pointers are rebuilt recursively. A value
+// that does not fit is recorded as a mismatch at its own path and ok is false.
+func (d *decoder) decode(path string, v any, typ reflect.Type) (reflect.Value, bool) {
+	if f, ok := v.(DocumentField); v == nil || ok && f.Value == nil {
+		return reflect.Zero(typ), true
+	}
+	if out, handled := d.decodeCustom(path, v, typ); handled {
+		return out, out.IsValid()
+	}
+	if f, ok := v.(DocumentField); ok {
+		if d.opts.strictTypes && !fieldFitsType(f.Type, typ) {
+			d.fail(path, FieldErrorTypeMismatch, typ.String(), string(f.Type))
+			return reflect.Value{}, false
+		}
+		v = f.Value
+	}
+	src := reflect.ValueOf(v)
+	mismatch := func() (reflect.Value, bool) {
+		d.fail(path, FieldErrorTypeMismatch, typ.String(), storedTypeName(v))
+		return reflect.Value{}, false
+	}
+	switch typ.Kind() {
+	case reflect.Pointer:
+		elem, ok := d.decode(path, v, typ.Elem())
+		if !ok {
+			return reflect.Value{}, false
+		}
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, true
+	case reflect.Interface:
+		plain := reflect.ValueOf(plainValue(v))
+		if !plain.Type().Implements(typ) {
+			return mismatch()
+		}
+		out := reflect.New(typ).Elem()
+		out.Set(plain)
+		return out, true
+	}
+	if d.opts.strictTypes {
+		if actual, ok := goFieldType(src.Type()); ok && !fieldFitsType(actual, typ) {
+			return mismatch()
+		}
+		if isNumberKind(src) && !numberFits(src, typ) {
+			return mismatch()
+		}
+	}
+	if src.Type().ConvertibleTo(typ) {
+		return src.Convert(typ), true
+	}
+	switch {
+	case typ.Kind() == reflect.Struct && src.Kind() == reflect.Map && src.Type().Key().Kind() == reflect.String:
+		out := reflect.New(typ).Elem()
+		d.decodeStruct(path, src, out)
+		return out, true
+	case (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) &&
+		(src.Kind() == reflect.Slice || src.Kind() == reflect.Array):
+		var out reflect.Value
+		if typ.Kind() == reflect.Slice {
+			out = reflect.MakeSlice(typ, src.Len(), src.Len())
+		} else {
+			if d.opts.strictTypes && src.Len() > typ.Len() {
+				return mismatch()
+			}
+			out = reflect.New(typ).Elem()
+		}
+		ok := true
+		for i := 0; i < src.Len() && i < out.Len(); i++ {
+			elem, elemOK := d.decode(fmt.Sprintf("%s[%d]", path, i), src.Index(i).Interface(), typ.Elem())
+			if !elemOK {
+				ok = false
+				continue
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, ok
+	case typ.Kind() == reflect.Map && typ.Key().Kind() == reflect.String &&
+		src.Kind() == reflect.Map && src.Type().Key().Kind() == reflect.String:
+		out := reflect.MakeMapWithSize(typ, src.Len())
+		ok := true
+		iter := src.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			elem, elemOK := d.decode(joinPath(path, key), iter.Value().Interface(), typ.Elem())
+			if !elemOK {
+				ok = false
+				continue
+			}
+			out.SetMapIndex(reflect.ValueOf(key).Convert(typ.Key()), elem)
+		}
+		return out, ok
+	}
+	return mismatch()
+}
+
+// fieldFitsType reports whether a field of type t can be decoded into typ
+// without changing its meaning.
+func fieldFitsType(t DocumentFieldType, typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Interface {
+		return true
+	}
+	expected, ok := goFieldType(typ)
+	return ok && expected == t
+}
+
+// numberFits reports whether a number converts to typ without losing precision.
+func numberFits(v reflect.Value, typ reflect.Type) bool {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	target := reflect.New(typ).Elem()
+	switch {
+	case isIntKind(typ.Kind()):
+		switch {
+		case isIntKind(v.Kind()):
+			return !target.OverflowInt(v.Int())
+		case isUintKind(v.Kind()):
+			return v.Uint() <= math.MaxInt64 && !target.OverflowInt(int64(v.Uint()))
+		}
+		f := v.Float()
+		return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 && !target.OverflowInt(int64(f))
+	case isUintKind(typ.Kind()):
+		switch {
+		case isIntKind(v.Kind()):
+			return v.Int() >= 0 && !target.OverflowUint(uint64(v.Int()))
+		case isUintKind(v.Kind()):
+			return !target.OverflowUint(v.Uint())
+		}
+		f := v.Float()
+		return f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 && !target.OverflowUint(uint64(f))
+	case typ.Kind() == reflect.Float32:
+		return !target.OverflowFloat(toFloat(v))
+	}
+	return true
+}
+
+func storedTypeName(v any) string {
+	if f, ok := v.(DocumentField); ok {
+		return string(f.Type)
+	}
+	if v == nil {
+		return "null"
+	}
+	if t, ok := goFieldType(reflect.TypeOf(v)); ok {
+		return string(t)
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// plainValue strips DocumentField wrappers from a tree, returning []any and
+// map[string]any for arrays and objects.
+func plainValue(v any) any {
+	switch v := v.(type) {
+	case DocumentField:
+		return plainValue(v.Value)
+	case []DocumentField:
+		out := make([]any, len(v))
+		for i, elem := range v {
+			out[i] = plainValue(elem)
+		}
+		return out
+	case map[string]DocumentField:
+		out := make(map[string]any, len(v))
+		for k, elem := range v {
+			out[k] = plainValue(elem)
+		}
+		return out
+	}
+	return v
+}
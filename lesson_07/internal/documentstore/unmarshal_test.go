@@ -0,0 +1,118 @@
+package documentstore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type strictProfile struct {
+	ID      string            `json:"id"`
+	Age     int8              `json:"age"`
+	Home    address           `json:"home"`
+	Tags    []string          `json:"tags"`
+	Limits  map[string]uint   `json:"limits"`
+	Nick    string            `json:"nick,omitempty"`
+	Manager *strictProfile    `json:"manager,omitempty"`
+	Extra   map[string]string `json:"extra,omitempty"`
+}
+
+func profileDoc() *Document {
+	return &Document{Fields: map[string]DocumentField{
+		"id":  {Type: DocumentFieldTypeString, Value: "p1"},
+		"age": {Type: DocumentFieldTypeNumber, Value: int64(300)},
+		"home": {Type: DocumentFieldTypeObject, Value: map[string]DocumentField{
+			"city":    {Type: DocumentFieldTypeNumber, Value: 5},
+			"country": {Type: DocumentFieldTypeString, Value: "UA"},
+		}},
+		"tags": {Type: DocumentFieldTypeArray, Value: []DocumentField{
+			{Type: DocumentFieldTypeString, Value: "a"},
+			{Type: DocumentFieldTypeBool, Value: true},
+		}},
+		"limits": {Type: DocumentFieldTypeObject, Value: map[string]any{"cpu": -1, "mem": 2.0}},
+		"color":  {Type: DocumentFieldTypeString, Value: "red"},
+	}}
+}
+
+func TestUnmarshalDocument_Lenient(t *testing.T) {
+	var p strictProfile
+	require.NoError(t, UnmarshalDocument(profileDoc(), &p))
+	assert.Equal(t, "p1", p.ID)
+	// tags has a bool element, the whole slice is skipped
+	assert.Nil(t, p.Tags)
+
+	t.Run("nil values", func(t *testing.T) {
+		doc := &Document{Fields: map[string]DocumentField{
+			"id":      {Type: DocumentFieldTypeString},
+			"manager": {Type: DocumentFieldTypeObject},
+			"tags":    {Type: DocumentFieldTypeArray},
+		}}
+		var p strictProfile
+		require.NoError(t, UnmarshalDocument(doc, &p, StrictTypes()))
+		assert.Equal(t, "", p.ID)
+		assert.Nil(t, p.Manager)
+	})
+}
+
+func TestUnmarshalDocument_Strict(t *testing.T) {
+	var p strictProfile
+	err := UnmarshalDocument(profileDoc(), &p, Strict())
+	require.ErrorIs(t, err, ErrUnmarshalStrict)
+
+	var uerr *UnmarshalError
+	require.True(t, errors.As(err, &uerr))
+	assert.ElementsMatch(t, []FieldError{
+		{Path: "age", Kind: FieldErrorTypeMismatch, Expected: "int8", Actual: "number"},
+		{Path: "home.city", Kind: FieldErrorTypeMismatch, Expected: "string", Actual: "number"},
+		{Path: "home.zip", Kind: FieldErrorMissing, Expected: "string"},
+		{Path: "home.country", Kind: FieldErrorUnknown, Actual: "string"},
+		{Path: "tags[1]", Kind: FieldErrorTypeMismatch, Expected: "string", Actual: "bool"},
+		{Path: "limits.cpu", Kind: FieldErrorTypeMismatch, Expected: "uint", Actual: "number"},
+		{Path: "color", Kind: FieldErrorUnknown, Actual: "string"},
+	}, uerr.Fields)
+	assert.Contains(t, err.Error(), "home.city: type mismatch: expected string, got number")
+	// fields that fit are still decoded
+	assert.Equal(t, "p1", p.ID)
+	assert.Nil(t, p.Limits)
+}
+
+func TestUnmarshalDocument_Options(t *testing.T) {
+	t.Run("unknown only", func(t *testing.T) {
+		var p strictProfile
+		var uerr *UnmarshalError
+		err := UnmarshalDocument(profileDoc(), &p, DisallowUnknownFields())
+		require.True(t, errors.As(err, &uerr))
+		assert.Len(t, uerr.Fields, 2)
+		for _, f := range uerr.Fields {
+			assert.Equal(t, FieldErrorUnknown, f.Kind)
+		}
+	})
+
+	t.Run("required only", func(t *testing.T) {
+		var p strictProfile
+		var uerr *UnmarshalError
+		doc := &Document{Fields: map[string]DocumentField{"id": {Type: DocumentFieldTypeString, Value: "p1"}}}
+		err := UnmarshalDocument(doc, &p, RequireFields())
+		require.True(t, errors.As(err, &uerr))
+		paths := make([]string, 0, len(uerr.Fields))
+		for _, f := range uerr.Fields {
+			paths = append(paths, f.Path)
+		}
+		assert.ElementsMatch(t, []string{"age", "home", "tags", "limits"}, paths)
+	})
+
+	t.Run("types only", func(t *testing.T) {
+		var u user
+		doc := &Document{Fields: map[string]DocumentField{
+			"name": {Type: DocumentFieldTypeNumber, Value: 123},
+			"id":   {Type: DocumentFieldTypeNumber, Value: 1.5},
+		}}
+		err := UnmarshalDocument(doc, &u, StrictTypes())
+		var uerr *UnmarshalError
+		require.True(t, errors.As(err, &uerr))
+		assert.Len(t, uerr.Fields, 2)
+		assert.Empty(t, u.Name)
+	})
+}
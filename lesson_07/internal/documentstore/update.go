@@ -0,0 +1,302 @@
+package documentstore
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+var (
+	ErrInvalidUpdate      = errors.New("[Collection] Error: invalid update")
+	ErrUpdateTypeMismatch = errors.New("[Collection] Error: update does not match field type")
+)
+
+type UpdateOperator string
+
+const (
+	UpdateOpSet    UpdateOperator = "$set"
+	UpdateOpUnset  UpdateOperator = "$unset"
+	UpdateOpInc    UpdateOperator = "$inc"
+	UpdateOpPush   UpdateOperator = "$push"
+	UpdateOpPull   UpdateOperator = "$pull"
+	UpdateOpRename UpdateOperator = "$rename"
+)
+
+// UpdateOp is a single modification applied by Collection.Update.
+// For $rename Value holds the new field name.
+type UpdateOp struct {
+	Op    UpdateOperator
+	Field string
+	Value any
+}
+
+// Set assigns a value. An existing field keeps its type: to change it,
+// Unset the field first in the same Update.
+func Set(field string, value any) UpdateOp {
+	return UpdateOp{Op: UpdateOpSet, Field: field, Value: value}
+}
+
+func Unset(field string) UpdateOp { return UpdateOp{Op: UpdateOpUnset, Field: field} }
+
+// Inc adds delta to a number field, creating it when missing.
+func Inc(field string, delta any) UpdateOp {
+	return UpdateOp{Op: UpdateOpInc, Field: field, Value: delta}
+}
+
+// Push appends a value to an array field, creating it when missing.
+func Push(field string, value any) UpdateOp {
+	return UpdateOp{Op: UpdateOpPush, Field: field, Value: value}
+}
+
+// Pull removes all elements equal to value from an array field.
+func Pull(field string, value any) UpdateOp {
+	return UpdateOp{Op: UpdateOpPull, Field: field, Value: value}
+}
+
+func Rename(field, newName string) UpdateOp {
+	return UpdateOp{Op: UpdateOpRename, Field: field, Value: newName}
+}
+
+func (op UpdateOp) validate(primaryKey string) error {
+	if strings.TrimSpace(op.Field) == "" {
+		return fmt.Errorf("%w: %s requires a field", ErrInvalidUpdate, op.Op)
+	}
+	if op.Field == primaryKey {
+		return fmt.Errorf("%w: primary key '%s' cannot be updated", ErrInvalidUpdate, primaryKey)
+	}
+	switch op.Op {
+	case UpdateOpSet, UpdateOpPush, UpdateOpPull:
+		if _, ok := fieldFromValue(op.Value); !ok {
+			return fmt.Errorf("%w: unsupported value %v for field %s", ErrInvalidUpdate, op.Value, op.Field)
+		}
+	case UpdateOpUnset:
+	case UpdateOpInc:
+		if !isNumberKind(reflect.ValueOf(op.Value)) {
+			return fmt.Errorf("%w: $inc expects a number for field %s", ErrInvalidUpdate, op.Field)
+		}
+	case UpdateOpRename:
+		name, ok := op.Value.(string)
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%w: $rename expects a new field name for %s", ErrInvalidUpdate, op.Field)
+		}
+		if name == primaryKey {
+			return fmt.Errorf("%w: primary key '%s' cannot be updated", ErrInvalidUpdate, primaryKey)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidUpdate, op.Op)
+	}
+	return nil
+}
+
+// Update applies ops to the document with the given key atomically and returns
+// the updated document. Either all ops are applied or none.
+func (s *Collection) Update(key string, ops ...UpdateOp) (*Document, error) {
+	if strings.TrimSpace(key) == "" {
+		pkgLogger.Error("[Collection Update] Error: key is empty")
+		return nil, ErrKeyEmpty
+	}
+	for _, op := range ops {
+		if err := op.validate(s.cfg.PrimaryKey); err != nil {
+			pkgLogger.Error("[Collection Update] invalid update", slog.Any("error", err))
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped {
+		pkgLogger.Error("[Collection Update] Error: collection was deleted", slog.String("key", key))
+		return nil, ErrCollectionNotFound
+	}
+	current, ok := s.documents[key]
+	if !ok {
+		pkgLogger.Error(fmt.Sprintf("[Collection Update] Document with key '%s' not found", key))
+		return nil, ErrDocumentNotFound
+	}
+
+	updated := Document{Fields: make(map[string]DocumentField, len(current.Fields))}
+	for name, field := range current.Fields {
+		updated.Fields[name] = field
+	}
+	for _, op := range ops {
+		if err := applyUpdateOp(&updated, op); err != nil {
+			pkgLogger.Error("[Collection Update] update rejected", slog.String("key", key), slog.Any("error", err))
+			return nil, err
+		}
+	}
+	if err := s.writeLocked(key, &updated, ChangeUpdate); err != nil {
+		pkgLogger.Error("[Collection Update] update rejected", slog.String("key", key), slog.Any("error", err))
+		return nil, err
+	}
+	pkgLogger.Info(fmt.Sprintf("[Collection Update] Document with key '%s' updated", key), slog.Int("ops", len(ops)))
+	result := updated
+	return &result, nil
+}
+
+func applyUpdateOp(doc *Document, op UpdateOp) error {
+	existing, exists := doc.GetPath(op.Field)
+	element := op.Value
+	if f, ok := element.(DocumentField); ok {
+		element = f.Value
+	}
+	var result DocumentField
+	switch op.Op {
+	case UpdateOpSet:
+		value, _ := fieldFromValue(op.Value)
+		if exists && existing.Type != value.Type {
+			return fmt.Errorf("%w: field %s is %s, got %s", ErrUpdateTypeMismatch, op.Field, existing.Type, value.Type)
+		}
+		result = value
+	case UpdateOpUnset:
+		return doc.DeletePath(op.Field)
+	case UpdateOpRename:
+		if !exists {
+			return nil
+		}
+		if err := doc.DeletePath(op.Field); err != nil {
+			return err
+		}
+		op.Field = op.Value.(string)
+		result = existing
+	case UpdateOpInc:
+		if !exists {
+			result = DocumentField{Type: DocumentFieldTypeNumber, Value: op.Value}
+			break
+		}
+		sum, err := addNumbers(existing, op.Value)
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrUpdateTypeMismatch, op.Field, err)
+		}
+		result = DocumentField{Type: existing.Type, Value: sum}
+	case UpdateOpPush:
+		if !exists {
+			result = DocumentField{Type: DocumentFieldTypeArray, Value: []any{element}}
+			break
+		}
+		arr, err := arrayValue(existing)
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrUpdateTypeMismatch, op.Field, err)
+		}
+		result = DocumentField{Type: DocumentFieldTypeArray, Value: appendElement(arr, element)}
+	case UpdateOpPull:
+		if !exists {
+			return nil
+		}
+		arr, err := arrayValue(existing)
+		if err != nil {
+			return fmt.Errorf("%w: field %s: %v", ErrUpdateTypeMismatch, op.Field, err)
+		}
+		kept := reflect.MakeSlice(reflect.SliceOf(arr.Type().Elem()), 0, arr.Len())
+		for i := 0; i < arr.Len(); i++ {
+			if !equalValues(arr.Index(i).Interface(), element) {
+				kept = reflect.Append(kept, arr.Index(i))
+			}
+		}
+		result = DocumentField{Type: DocumentFieldTypeArray, Value: kept.Interface()}
+	default:
+		return nil
+	}
+	if err := doc.SetPath(op.Field, result); err != nil {
+		return fmt.Errorf("%w: %v", ErrUpdateTypeMismatch, err)
+	}
+	return nil
+}
+
+// addNumbers keeps the Go type of the stored value when both operands are
+// integers, failing when the sum does not fit it, and falls back to float64
+// otherwise. Decimals stay exact.
+func addNumbers(field DocumentField, delta any) (any, error) {
+	if field.Type == DocumentFieldTypeDecimal {
+		cur, ok := toRat(field.Value)
+		d, dok := toRat(delta)
+		if !ok || !dok {
+			return nil, fmt.Errorf("is decimal, cannot add %v", delta)
+		}
+		return new(big.Rat).Add(cur, d), nil
+	}
+	cur := reflect.ValueOf(field.Value)
+	d := reflect.ValueOf(delta)
+	if field.Type != DocumentFieldTypeNumber || !isNumberKind(cur) {
+		return nil, fmt.Errorf("is %s, not a number", field.Type)
+	}
+	if (!isIntKind(d.Kind()) && !isUintKind(d.Kind())) || (!isIntKind(cur.Kind()) && !isUintKind(cur.Kind())) {
+		return toFloat(cur) + toFloat(d), nil
+	}
+	overflow := fmt.Errorf("%v + %v overflows %s", field.Value, delta, cur.Type())
+	sum := reflect.New(cur.Type()).Elem()
+	if isIntKind(cur.Kind()) {
+		if isUintKind(d.Kind()) && d.Uint() > math.MaxInt64 {
+			return nil, overflow
+		}
+		var di int64
+		if isUintKind(d.Kind()) {
+			di = int64(d.Uint())
+		} else {
+			di = d.Int()
+		}
+		total := cur.Int() + di
+		if (di > 0 && total < cur.Int()) || (di < 0 && total > cur.Int()) || cur.OverflowInt(total) {
+			return nil, overflow
+		}
+		sum.SetInt(total)
+		return sum.Interface(), nil
+	}
+	var total uint64
+	if isIntKind(d.Kind()) && d.Int() < 0 {
+		// -(d+1)+1 avoids negating math.MinInt64
+		neg := uint64(-(d.Int() + 1)) + 1
+		if neg > cur.Uint() {
+			return nil, overflow
+		}
+		total = cur.Uint() - neg
+	} else {
+		var du uint64
+		if isUintKind(d.Kind()) {
+			du = d.Uint()
+		} else {
+			du = uint64(d.Int())
+		}
+		total = cur.Uint() + du
+		if total < cur.Uint() {
+			return nil, overflow
+		}
+	}
+	if cur.OverflowUint(total) {
+		return nil, overflow
+	}
+	sum.SetUint(total)
+	return sum.Interface(), nil
+}
+
+func arrayValue(field DocumentField) (reflect.Value, error) {
+	v := reflect.ValueOf(field.Value)
+	if field.Type != DocumentFieldTypeArray || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+		return reflect.Value{}, fmt.Errorf("is %s, not an array", field.Type)
+	}
+	return v, nil
+}
+
+// appendElement returns a new slice, so documents sharing the old one are not affected.
+// The element type of the slice is kept when the value fits into it.
+func appendElement(arr reflect.Value, value any) any {
+	if arr.Type().Elem() == documentFieldType {
+		if f, ok := fieldFromValue(value); ok {
+			value = f
+		}
+	}
+	v := reflect.ValueOf(value)
+	if v.IsValid() && arr.Kind() == reflect.Slice && v.Type().AssignableTo(arr.Type().Elem()) {
+		result := reflect.MakeSlice(arr.Type(), arr.Len(), arr.Len()+1)
+		reflect.Copy(result, arr)
+		return reflect.Append(result, v).Interface()
+	}
+	result := make([]any, 0, arr.Len()+1)
+	for i := 0; i < arr.Len(); i++ {
+		result = append(result, arr.Index(i).Interface())
+	}
+	return append(result, value)
+}
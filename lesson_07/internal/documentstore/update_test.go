@@ -0,0 +1,174 @@
+package documentstore
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProfileCollection(t *testing.T) *Collection {
+	t.Helper()
+	col := NewCollection(&CollectionConfig{
+		PrimaryKey: "id",
+		Unique:     []UniqueConstraint{{Fields: []string{"email"}}},
+	})
+	require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+		"id":     {Type: DocumentFieldTypeString, Value: "u1"},
+		"name":   {Type: DocumentFieldTypeString, Value: "Alice"},
+		"email":  {Type: DocumentFieldTypeString, Value: "alice@example.com"},
+		"visits": {Type: DocumentFieldTypeNumber, Value: 1},
+		"roles":  {Type: DocumentFieldTypeArray, Value: []string{"user", "editor", "user"}},
+	}}))
+	return col
+}
+
+func TestCollection_Update(t *testing.T) {
+	col := newProfileCollection(t)
+
+	doc, err := col.Update("u1",
+		Set("name", "Alicia"),
+		Inc("visits", 2),
+		Inc("score", 0.5),
+		Push("roles", "admin"),
+		Pull("roles", "user"),
+		Rename("email", "contact"),
+		Unset("missing"),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Alicia", doc.Fields["name"].Value)
+	assert.Equal(t, 3, doc.Fields["visits"].Value)
+	assert.Equal(t, 0.5, doc.Fields["score"].Value)
+	assert.Equal(t, []string{"editor", "admin"}, doc.Fields["roles"].Value)
+	assert.Equal(t, "alice@example.com", doc.Fields["contact"].Value)
+	_, hasEmail := doc.Fields["email"]
+	assert.False(t, hasEmail)
+
+	stored, err := col.Get("u1")
+	require.NoError(t, err)
+	assert.Equal(t, doc.Fields, stored.Fields)
+}
+
+func TestCollection_Update_Atomic(t *testing.T) {
+	col := newProfileCollection(t)
+	before, err := col.Get("u1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		ops  []UpdateOp
+		err  error
+	}{
+		{"set changes type", []UpdateOp{Set("visits", 1), Set("name", 5)}, ErrUpdateTypeMismatch},
+		{"inc non number", []UpdateOp{Inc("name", 1)}, ErrUpdateTypeMismatch},
+		{"push non array", []UpdateOp{Push("name", "x")}, ErrUpdateTypeMismatch},
+		{"inc with string", []UpdateOp{Inc("visits", "1")}, ErrInvalidUpdate},
+		{"primary key", []UpdateOp{Set("id", "u2")}, ErrInvalidUpdate},
+		{"rename to primary key", []UpdateOp{Rename("name", "id")}, ErrInvalidUpdate},
+		{"unknown operator", []UpdateOp{{Op: "$mul", Field: "visits", Value: 2}}, ErrInvalidUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := col.Update("u1", tt.ops...)
+			assert.ErrorIs(t, err, tt.err)
+			after, err := col.Get("u1")
+			require.NoError(t, err)
+			assert.Equal(t, before.Fields, after.Fields)
+		})
+	}
+
+	t.Run("unique constraint", func(t *testing.T) {
+		require.NoError(t, col.Put(Document{Fields: map[string]DocumentField{
+			"id":    {Type: DocumentFieldTypeString, Value: "u2"},
+			"email": {Type: DocumentFieldTypeString, Value: "bob@example.com"},
+		}}))
+		_, err := col.Update("u2", Set("email", "alice@example.com"))
+		assert.ErrorIs(t, err, ErrUniqueViolation)
+	})
+
+	t.Run("type change via unset", func(t *testing.T) {
+		doc, err := col.Update("u1", Unset("visits"), Set("visits", "many"))
+		require.NoError(t, err)
+		assert.Equal(t, DocumentFieldTypeString, doc.Fields["visits"].Type)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := col.Update("missing", Set("a", 1))
+		assert.ErrorIs(t, err, ErrDocumentNotFound)
+		_, err = col.Update(" ", Set("a", 1))
+		assert.ErrorIs(t, err, ErrKeyEmpty)
+	})
+}
+
+func TestCollection_Update_Concurrent(t *testing.T) {
+	col := newProfileCollection(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := col.Update("u1", Inc("visits", 1), Push("tags", fmt.Sprintf("t%d", i)))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	doc, err := col.Get("u1")
+	require.NoError(t, err)
+	assert.Equal(t, 51, doc.Fields["visits"].Value)
+	assert.Len(t, doc.Fields["tags"].Value, 50)
+}
+
+func TestAddNumbers_Integers(t *testing.T) {
+	number := func(v any) DocumentField { return DocumentField{Type: DocumentFieldTypeNumber, Value: v} }
+	tests := []struct {
+		name  string
+		field DocumentField
+		delta any
+		want  any
+	}{
+		{"int8", number(int8(100)), 27, int8(127)},
+		{"int with uint delta", number(5), uint(3), 8},
+		{"uint with negative delta", number(uint(5)), -5, uint(0)},
+		{"uint64 with min int64", number(uint64(math.MaxUint64)), math.MinInt64, uint64(math.MaxInt64)},
+		{"int with float delta", number(1), 0.5, 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := addNumbers(tt.field, tt.delta)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	overflows := []struct {
+		name  string
+		field DocumentField
+		delta any
+	}{
+		{"int8", number(int8(127)), 1},
+		{"int8 below min", number(int8(-128)), -1},
+		{"int64", number(int64(math.MaxInt64)), 1},
+		{"int64 below min", number(int64(math.MinInt64)), -1},
+		{"int with huge uint delta", number(0), uint64(math.MaxUint64)},
+		{"uint below zero", number(uint(1)), -2},
+		{"uint8", number(uint8(255)), uint8(1)},
+		{"uint64", number(uint64(math.MaxUint64)), 1},
+	}
+	for _, tt := range overflows {
+		t.Run(tt.name+" overflows", func(t *testing.T) {
+			_, err := addNumbers(tt.field, tt.delta)
+			assert.ErrorContains(t, err, "overflows")
+		})
+	}
+
+	col := newProfileCollection(t)
+	_, err := col.Update("u1", Set("visits", int8(127)))
+	require.NoError(t, err)
+	_, err = col.Update("u1", Inc("visits", 1))
+	assert.ErrorIs(t, err, ErrUpdateTypeMismatch)
+}
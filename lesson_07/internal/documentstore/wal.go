@@ -0,0 +1,430 @@
+package documentstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWALPath      = errors.New("[WAL] Error: log file is not specified")
+	ErrWALCorrupted = errors.New("[WAL] Error: log is corrupted")
+	ErrWALFsync     = errors.New("[WAL] Error: unknown fsync policy")
+	ErrWALNotOpened = errors.New("[WAL] Error: store has no write-ahead log")
+)
+
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs the log after every record.
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval syncs the log in the background every WALOptions.FsyncInterval.
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever leaves syncing to the operating system.
+	FsyncNever FsyncPolicy = "never"
+)
+
+const defaultFsyncInterval = time.Second
+
+type WALOptions struct {
+	Path          string
+	Fsync         FsyncPolicy
+	FsyncInterval time.Duration
+}
+
+type walOp string
+
+const (
+	walOpCreateCollection walOp = "create_collection"
+	walOpDeleteCollection walOp = "delete_collection"
+	walOpPut              walOp = "put"
+	walOpDelete           walOp = "delete"
+	walOpTx               walOp = "tx"
+)
+
+// walRecord is one line of the log. Records of a transaction are nested in a
+// single tx record so that they are replayed all together or not at all.
+type walRecord struct {
+	Sequence   uint64            `json:"seq,omitempty"`
+	Op         walOp             `json:"op"`
+	Collection string            `json:"collection,omitempty"`
+	Config     *CollectionConfig `json:"config,omitempty"`
+	Schema     *Schema           `json:"schema,omitempty"`
+	Document   *Document         `json:"document,omitempty"`
+	Key        string            `json:"key,omitempty"`
+	// KeySequence of the collection after a put, see KeyStrategySequence.
+	KeySequence uint64      `json:"keySeq,omitempty"`
+	Records     []walRecord `json:"records,omitempty"`
+}
+
+// writeAheadLog is an append-only file with one JSON record per line.
+type writeAheadLog struct {
+	mu       sync.Mutex
+	file     *os.File
+	policy   FsyncPolicy
+	sequence uint64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (w *writeAheadLog) append(rec walRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rec.Sequence = w.sequence + 1
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode wal record: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err := w.file.Write(data); err != nil {
+		return fmt.Errorf("failed to write wal record: %w", err)
+	}
+	if w.policy == FsyncAlways {
+		if err := w.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync wal: %w", err)
+		}
+	}
+	w.sequence = rec.Sequence
+	return nil
+}
+
+func (w *writeAheadLog) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Sync()
+}
+
+// truncate drops all records, the sequence keeps growing.
+func (w *writeAheadLog) truncate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *writeAheadLog) syncEvery(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.sync(); err != nil {
+				pkgLogger.Error("[WAL] background sync failed", slog.Any("error", err))
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *writeAheadLog) close() error {
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// OpenStore loads the snapshot file (if it exists), replays the write-ahead log
+// on top of it and keeps logging every mutation of the store to the log.
+// Use Checkpoint to write a new snapshot and truncate the log, and Close to stop logging.
+func OpenStore(snapshotFile string, opts WALOptions) (*Store, error) {
+	opts.Path = strings.TrimSpace(opts.Path)
+	if opts.Path == "" {
+		pkgLogger.Error("[WAL] log file is not specified")
+		return nil, ErrWALPath
+	}
+	switch opts.Fsync {
+	case "":
+		opts.Fsync = FsyncAlways
+	case FsyncAlways, FsyncNever:
+	case FsyncInterval:
+		if opts.FsyncInterval <= 0 {
+			opts.FsyncInterval = defaultFsyncInterval
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrWALFsync, opts.Fsync)
+	}
+
+	store := NewStore()
+	if strings.TrimSpace(snapshotFile) != "" {
+		if _, err := os.Stat(snapshotFile); err == nil {
+			loaded, err := NewStoreFromFile(snapshotFile)
+			if err != nil {
+				return nil, err
+			}
+			store = loaded
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %v", ErrReadStoreDump, err)
+		}
+	}
+
+	validSize, lastSequence, err := store.replayWAL(opts.Path)
+	if err != nil {
+		pkgLogger.Error("[WAL] replay failed", slog.String("file", opts.Path), slog.Any("error", err))
+		return nil, err
+	}
+
+	file, err := os.OpenFile(opts.Path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wal: %w", err)
+	}
+	// drop a partially written last record
+	if err := file.Truncate(validSize); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to open wal: %w", err)
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to open wal: %w", err)
+	}
+
+	wal := &writeAheadLog{
+		file:     file,
+		policy:   opts.Fsync,
+		sequence: max(lastSequence, store.walSequence),
+	}
+	if opts.Fsync == FsyncInterval {
+		wal.stop = make(chan struct{})
+		wal.done = make(chan struct{})
+		go wal.syncEvery(opts.FsyncInterval)
+	}
+
+	store.mu.Lock()
+	store.wal = wal
+	for name, coll := range store.collections {
+		coll.mu.Lock()
+		coll.name = name
+		coll.wal = wal
+		coll.mu.Unlock()
+	}
+	store.mu.Unlock()
+	pkgLogger.Info("[WAL] store opened", slog.String("file", opts.Path), slog.Uint64("sequence", wal.sequence))
+	return store, nil
+}
+
+// replayWAL applies log records newer than the loaded snapshot.
+// It returns the size of the valid part of the log and the last sequence.
+func (s *Store) replayWAL(path string) (int64, uint64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open wal: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	var lastSequence uint64
+	applied := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				pkgLogger.Warn("[WAL] ignoring partially written last record", slog.Int64("offset", offset))
+			}
+			break
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to read wal: %w", err)
+		}
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return 0, 0, fmt.Errorf("%w: record at offset %d: %v", ErrWALCorrupted, offset, err)
+		}
+		offset += int64(len(line))
+		lastSequence = rec.Sequence
+		if rec.Sequence <= s.walSequence {
+			continue // already in the snapshot
+		}
+		if err := s.applyWALRecord(rec); err != nil {
+			return 0, 0, fmt.Errorf("%w: record %d: %v", ErrWALCorrupted, rec.Sequence, err)
+		}
+		applied++
+	}
+	pkgLogger.Info("[WAL] replayed", slog.String("file", path), slog.Int("records", applied))
+	return offset, lastSequence, nil
+}
+
+func (s *Store) applyWALRecord(rec walRecord) error {
+	switch rec.Op {
+	case walOpCreateCollection:
+		if rec.Config == nil {
+			return errors.New("collection config is missing")
+		}
+		cfg := *rec.Config
+		cfg.Schema = rec.Schema
+		_, err := s.CreateCollection(rec.Collection, &cfg)
+		if errors.Is(err, ErrCollectionAlreadyExists) {
+			return nil
+		}
+		return err
+	case walOpDeleteCollection:
+		err := s.DeleteCollection(rec.Collection)
+		if errors.Is(err, ErrCollectionNotFound) {
+			return nil
+		}
+		return err
+	case walOpPut:
+		if rec.Document == nil {
+			return errors.New("document is missing")
+		}
+		coll, err := s.GetCollection(rec.Collection)
+		if err != nil {
+			return err
+		}
+		if err := coll.restore(*rec.Document); err != nil {
+			return err
+		}
+		coll.mu.Lock()
+		coll.keySequence = max(coll.keySequence, rec.KeySequence)
+		coll.mu.Unlock()
+		return nil
+	case walOpDelete:
+		coll, err := s.GetCollection(rec.Collection)
+		if err != nil {
+			return err
+		}
+		coll.mu.Lock()
+		coll.removeLocked(rec.Key)
+		coll.mu.Unlock()
+		return nil
+	case walOpTx:
+		for _, nested := range rec.Records {
+			if err := s.applyWALRecord(nested); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown operation %q", rec.Op)
+}
+
+// logLocked writes records of this collection to the write-ahead log, or to
+// the batch of a committing transaction. Must be called with the write lock held.
+func (s *Collection) logLocked(recs ...walRecord) error {
+	for i := range recs {
+		recs[i].Collection = s.name
+	}
+	if s.walBatch != nil {
+		*s.walBatch = append(*s.walBatch, recs...)
+		return nil
+	}
+	if s.wal == nil || len(recs) == 0 {
+		return nil
+	}
+	if len(recs) == 1 {
+		return s.wal.append(recs[0])
+	}
+	// several records, like a put with its evictions, are replayed all or none
+	return s.wal.append(walRecord{Op: walOpTx, Records: recs})
+}
+
+// Checkpoint writes a consistent snapshot of the store to snapshotFile and
+// truncates the write-ahead log. Writes wait until the checkpoint is finished.
+func (s *Store) Checkpoint(snapshotFile string) error {
+	snapshotFile = strings.TrimSpace(snapshotFile)
+	if snapshotFile == "" {
+		pkgLogger.Error("filename is empty")
+		return ErrCollectionFileName
+	}
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.wal == nil {
+		return ErrWALNotOpened
+	}
+
+	names := make([]string, 0, len(s.collections))
+	for name := range s.collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.collections[name].mu.Lock()
+		defer s.collections[name].mu.Unlock()
+	}
+
+	s.wal.mu.Lock()
+	sequence := s.wal.sequence
+	s.wal.mu.Unlock()
+
+	ds := dumpStore{Collections: make(map[string]dumpCollection, len(names)), WALSequence: sequence}
+	for _, name := range names {
+		ds.Collections[name] = s.collections[name].dumpLocked()
+	}
+	data, err := marshalDump(ds)
+	if err != nil {
+		return err
+	}
+	if err := writeDumpFile(snapshotFile, data, 0); err != nil {
+		return err
+	}
+	if err := s.wal.truncate(); err != nil {
+		pkgLogger.Error("[WAL] failed to truncate log after checkpoint", slog.Any("error", err))
+		return err
+	}
+	pkgLogger.Info("[WAL] checkpoint written", slog.String("file", snapshotFile), slog.Uint64("sequence", sequence))
+	return nil
+}
+
+// Close stops the write-ahead log of a store opened with OpenStore.
+// The store stays usable in memory, but mutations are no longer logged.
+// Channels returned by Watch are closed and expired documents are no longer
+// removed in the background.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	wal := s.wal
+	s.wal = nil
+	s.closed = true
+	reaperStop, reaperDone := s.reaperStop, s.reaperDone
+	s.reaperStop = nil
+	collections := make([]*Collection, 0, len(s.collections))
+	for _, coll := range s.collections {
+		collections = append(collections, coll)
+	}
+	s.mu.Unlock()
+	if reaperStop != nil {
+		close(reaperStop)
+		<-reaperDone
+	}
+	s.feed.close()
+	if wal == nil {
+		return nil
+	}
+	for _, coll := range collections {
+		coll.mu.Lock()
+		coll.wal = nil
+		coll.mu.Unlock()
+	}
+	if err := wal.close(); err != nil {
+		pkgLogger.Error("[WAL] failed to close log", slog.Any("error", err))
+		return err
+	}
+	pkgLogger.Info("[WAL] closed")
+	return nil
+}
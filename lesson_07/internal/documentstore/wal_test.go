@@ -0,0 +1,183 @@
+package documentstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func walPaths(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	return filepath.Join(dir, "snapshot.json"), filepath.Join(dir, "store.wal")
+}
+
+func fillWALStore(t *testing.T, s *Store) {
+	t.Helper()
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	_, err = s.CreateCollection("tmp", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteCollection("tmp"))
+
+	require.NoError(t, users.Put(userDoc("u1", "a@example.com", "t1")))
+	require.NoError(t, users.Put(userDoc("u2", "b@example.com", "t1")))
+	_, err = users.Update("u1", Set("email", "c@example.com"))
+	require.NoError(t, err)
+	require.NoError(t, users.Delete("u2"))
+	require.NoError(t, s.Tx(func(tx *Tx) error {
+		return tx.Put("users", userDoc("u3", "d@example.com", "t2"))
+	}))
+	// rolled back transactions are not logged
+	_ = s.Tx(func(tx *Tx) error {
+		_ = tx.Put("users", userDoc("u4", "e@example.com", "t2"))
+		return errors.New("abort")
+	})
+}
+
+func assertWALState(t *testing.T, s *Store) {
+	t.Helper()
+	_, err := s.GetCollection("tmp")
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+	users, err := s.GetCollection("users")
+	require.NoError(t, err)
+	docs := users.List()
+	require.Equal(t, []string{"u1", "u3"}, ids(docs))
+	assert.Equal(t, "c@example.com", docs[0].Fields["email"].Value)
+	assert.Equal(t, uint64(3), docs[0].Revision)
+	assert.Equal(t, uint64(4), docs[1].Revision)
+}
+
+func TestOpenStore_ReplaysLog(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+
+	s, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	fillWALStore(t, s)
+	require.NoError(t, s.Close())
+
+	reopened, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	defer reopened.Close()
+	assertWALState(t, reopened)
+}
+
+func TestOpenStore_DeletedCollectionHandle(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+	s, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	old, err := s.CreateCollection("c", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, old.Put(userDoc("u1", "a@example.com", "t1")))
+	require.NoError(t, s.DeleteCollection("c"))
+
+	assert.ErrorIs(t, old.Put(userDoc("u2", "b@example.com", "t1")), ErrCollectionNotFound)
+	assert.ErrorIs(t, old.Delete("u1"), ErrCollectionNotFound)
+	_, err = old.Update("u1", Set("email", "c@example.com"))
+	assert.ErrorIs(t, err, ErrCollectionNotFound)
+	assert.ErrorIs(t, old.PutIfRevision(userDoc("u4", "e@example.com", "t1"), 0), ErrCollectionNotFound)
+	assert.ErrorIs(t, old.DeleteIfRevision("u1", 1), ErrCollectionNotFound)
+	_, err = s.CreateCollection("c", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, old.Put(userDoc("u3", "d@example.com", "t1")), ErrCollectionNotFound)
+	require.NoError(t, s.Close())
+
+	reopened, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	defer reopened.Close()
+	c, err := reopened.GetCollection("c")
+	require.NoError(t, err)
+	assert.Empty(t, c.List(), "writes through the old handle are not replayed")
+}
+
+func TestOpenStore_Checkpoint(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+
+	s, err := OpenStore(snapshot, WALOptions{Path: walPath, Fsync: FsyncNever})
+	require.NoError(t, err)
+	fillWALStore(t, s)
+	require.NoError(t, s.Checkpoint(snapshot))
+
+	info, err := os.Stat(walPath)
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	users, _ := s.GetCollection("users")
+	require.NoError(t, users.Put(userDoc("u5", "f@example.com", "t3")))
+	require.NoError(t, s.Close())
+
+	reopened, err := OpenStore(snapshot, WALOptions{Path: walPath})
+	require.NoError(t, err)
+	defer reopened.Close()
+	users, _ = reopened.GetCollection("users")
+	assert.Equal(t, []string{"u1", "u3", "u5"}, ids(users.List()))
+
+	t.Run("records already in snapshot are skipped", func(t *testing.T) {
+		// simulate a crash between writing the snapshot and truncating the log
+		data, err := os.ReadFile(walPath)
+		require.NoError(t, err)
+		require.NoError(t, reopened.Checkpoint(snapshot))
+		require.NoError(t, reopened.Close())
+		require.NoError(t, os.WriteFile(walPath, data, 0644))
+
+		again, err := OpenStore(snapshot, WALOptions{Path: walPath})
+		require.NoError(t, err)
+		defer again.Close()
+		users, _ := again.GetCollection("users")
+		assert.Equal(t, []string{"u1", "u3", "u5"}, ids(users.List()))
+	})
+}
+
+func TestOpenStore_DamagedLog(t *testing.T) {
+	t.Run("partial last record is dropped", func(t *testing.T) {
+		snapshot, walPath := walPaths(t)
+		s, err := OpenStore(snapshot, WALOptions{Path: walPath, Fsync: FsyncInterval, FsyncInterval: time.Millisecond})
+		require.NoError(t, err)
+		fillWALStore(t, s)
+		require.NoError(t, s.Close())
+
+		f, err := os.OpenFile(walPath, os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"seq":99,"op":"put","collection":"us`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		reopened, err := OpenStore(snapshot, WALOptions{Path: walPath})
+		require.NoError(t, err)
+		assertWALState(t, reopened)
+		users, _ := reopened.GetCollection("users")
+		require.NoError(t, users.Put(userDoc("u6", "g@example.com", "t1")))
+		require.NoError(t, reopened.Close())
+
+		last, err := OpenStore(snapshot, WALOptions{Path: walPath})
+		require.NoError(t, err)
+		defer last.Close()
+		users, _ = last.GetCollection("users")
+		assert.Equal(t, []string{"u1", "u3", "u6"}, ids(users.List()))
+	})
+
+	t.Run("corrupted record fails", func(t *testing.T) {
+		snapshot, walPath := walPaths(t)
+		require.NoError(t, os.WriteFile(walPath, []byte("{broken\n"), 0644))
+		_, err := OpenStore(snapshot, WALOptions{Path: walPath})
+		assert.ErrorIs(t, err, ErrWALCorrupted)
+	})
+}
+
+func TestOpenStore_Errors(t *testing.T) {
+	snapshot, walPath := walPaths(t)
+
+	_, err := OpenStore(snapshot, WALOptions{Path: " "})
+	assert.ErrorIs(t, err, ErrWALPath)
+	_, err = OpenStore(snapshot, WALOptions{Path: walPath, Fsync: "sometimes"})
+	assert.ErrorIs(t, err, ErrWALFsync)
+
+	s := NewStore()
+	assert.ErrorIs(t, s.Checkpoint(snapshot), ErrWALNotOpened)
+	assert.NoError(t, s.Close())
+}
@@ -0,0 +1,245 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+var (
+	ErrResumeTokenExpired = errors.New("[Watch] Error: resume token is no longer in the change history")
+)
+
+type ChangeType string
+
+const (
+	ChangeInsert  ChangeType = "insert"
+	ChangeReplace ChangeType = "replace"
+	ChangeUpdate  ChangeType = "update"
+	ChangeDelete  ChangeType = "delete"
+	// ChangeExpire is a delete of an expired document, see CollectionConfig.ExpireField.
+	ChangeExpire ChangeType = "expire"
+	// ChangeEvict is a delete of the oldest document of a capped collection.
+	ChangeEvict ChangeType = "evict"
+	// ChangeCreate and ChangeDrop are sent when a collection of the Store is
+	// created or deleted, they have no Key and no documents.
+	ChangeCreate ChangeType = "create"
+	ChangeDrop   ChangeType = "drop"
+	// ChangeLagged is the last event of a watcher that did not keep up with
+	// the changes, see WatchOptions.BufferSize.
+	ChangeLagged ChangeType = "lagged"
+)
+
+const (
+	// changeHistorySize is the number of recent events kept for resuming.
+	changeHistorySize  = 1024
+	defaultWatchBuffer = 256
+)
+
+// ChangeEvent describes one change. Before is nil for inserts, After is nil
+// for deletes. Documents are shared with the collection and must not be modified.
+type ChangeEvent struct {
+	// Sequence increases by one with every change of the Store and is the
+	// token to resume after this event. Tokens are valid while the Store
+	// is open, they are not stored in dumps or the write-ahead log.
+	Sequence   uint64
+	Type       ChangeType
+	Collection string
+	Key        string
+	Before     *Document
+	After      *Document
+
+	// source is the collection the event belongs to.
+	source *Collection
+}
+
+type WatchOptions struct {
+	// Filter selects document changes by the document after the change,
+	// or before it for deletes. Nil selects all changes. Create and drop
+	// events are not filtered.
+	Filter *Filter
+	// ResumeAfter replays the kept events that follow this sequence before
+	// the new ones. Zero watches only new changes.
+	ResumeAfter uint64
+	// BufferSize is the number of events that may wait for the receiver,
+	// default 256. A watcher whose buffer is full gets a ChangeLagged event
+	// with the Sequence of the last event it was sent and its channel is
+	// closed, so writers never wait for slow receivers. Watch again with
+	// ResumeAfter set to that sequence to continue.
+	BufferSize int
+}
+
+// changeFeed numbers the changes of a Store, keeps the recent ones and
+// delivers them to watchers.
+type changeFeed struct {
+	mu       sync.Mutex
+	sequence uint64
+	history  []ChangeEvent
+	// next is the position of the oldest event once history is full.
+	next     int
+	watchers map[*watcher]struct{}
+}
+
+type watcher struct {
+	ch     chan ChangeEvent
+	done   chan struct{}
+	coll   *Collection
+	filter *Filter
+	size   int
+	last   uint64
+	closed bool
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{watchers: make(map[*watcher]struct{})}
+}
+
+func (f *changeFeed) publish(ev ChangeEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sequence++
+	ev.Sequence = f.sequence
+	if len(f.history) < changeHistorySize {
+		f.history = append(f.history, ev)
+	} else {
+		f.history[f.next] = ev
+		f.next = (f.next + 1) % changeHistorySize
+	}
+	for w := range f.watchers {
+		f.deliverLocked(w, ev)
+	}
+}
+
+// deliverLocked sends ev to w without blocking. Must be called with f.mu held.
+func (f *changeFeed) deliverLocked(w *watcher, ev ChangeEvent) {
+	if !w.matches(ev) {
+		w.last = ev.Sequence
+		return
+	}
+	if len(w.ch) >= w.size {
+		// the channel has one spare slot for the lagged event
+		pkgLogger.Warn("[Watch] watcher is too slow, dropping it", slog.Uint64("lastSequence", w.last))
+		w.ch <- ChangeEvent{Sequence: w.last, Type: ChangeLagged, Collection: ev.Collection}
+		f.closeLocked(w)
+		return
+	}
+	w.ch <- ev
+	w.last = ev.Sequence
+	if ev.Type == ChangeDrop && w.coll != nil {
+		f.closeLocked(w)
+	}
+}
+
+func (f *changeFeed) closeLocked(w *watcher) {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	delete(f.watchers, w)
+	close(w.ch)
+	close(w.done)
+}
+
+// watch registers a watcher of coll, or of the whole Store when coll is nil.
+func (f *changeFeed) watch(ctx context.Context, coll *Collection, opts WatchOptions) (<-chan ChangeEvent, error) {
+	if opts.Filter != nil {
+		if err := opts.Filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	size := opts.BufferSize
+	if size <= 0 {
+		size = defaultWatchBuffer
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var backlog []ChangeEvent
+	if opts.ResumeAfter > 0 {
+		if opts.ResumeAfter > f.sequence || opts.ResumeAfter+uint64(len(f.history)) < f.sequence {
+			return nil, fmt.Errorf("%w: %d", ErrResumeTokenExpired, opts.ResumeAfter)
+		}
+		for i := range f.history {
+			ev := f.history[(f.next+i)%len(f.history)]
+			if ev.Sequence > opts.ResumeAfter {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+	w := &watcher{
+		ch:     make(chan ChangeEvent, max(size, len(backlog))+1),
+		done:   make(chan struct{}),
+		coll:   coll,
+		filter: opts.Filter,
+		size:   max(size, len(backlog)),
+	}
+	f.watchers[w] = struct{}{}
+	for _, ev := range backlog {
+		f.deliverLocked(w, ev)
+	}
+	w.last = f.sequence
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.closeLocked(w)
+		case <-w.done:
+		}
+	}()
+	return w.ch, nil
+}
+
+func (w *watcher) matches(ev ChangeEvent) bool {
+	if w.coll != nil && ev.source != w.coll {
+		return false
+	}
+	if w.filter == nil || ev.Type == ChangeCreate || ev.Type == ChangeDrop {
+		return true
+	}
+	doc := ev.After
+	if doc == nil {
+		doc = ev.Before
+	}
+	return doc != nil && w.filter.Match(doc)
+}
+
+// close closes the channels of all watchers.
+func (f *changeFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for w := range f.watchers {
+		f.closeLocked(w)
+	}
+}
+
+// Watch returns a channel of changes of all collections of the Store.
+// The channel is closed when ctx is done, the Store is closed or the
+// receiver lags behind, see WatchOptions.
+func (s *Store) Watch(ctx context.Context, opts WatchOptions) (<-chan ChangeEvent, error) {
+	return s.feed.watch(ctx, nil, opts)
+}
+
+// Watch returns a channel of changes of the collection. Besides the cases
+// of Store.Watch the channel is closed after the collection is deleted.
+func (s *Collection) Watch(ctx context.Context, opts WatchOptions) (<-chan ChangeEvent, error) {
+	s.mu.RLock()
+	feed := s.feed
+	s.mu.RUnlock()
+	return feed.watch(ctx, s, opts)
+}
+
+// notifyLocked publishes a change of the collection, or keeps it until a
+// committing transaction succeeds. Must be called with the write lock held.
+func (s *Collection) notifyLocked(ev ChangeEvent) {
+	ev.Collection = s.name
+	ev.source = s
+	if s.eventBatch != nil {
+		*s.eventBatch = append(*s.eventBatch, ev)
+		return
+	}
+	s.feed.publish(ev)
+}
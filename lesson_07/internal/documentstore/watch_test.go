@@ -0,0 +1,198 @@
+package documentstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drain returns the events that are already waiting in ch and whether ch is closed.
+func drain(ch <-chan ChangeEvent) ([]ChangeEvent, bool) {
+	var events []ChangeEvent
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return events, true
+			}
+			events = append(events, ev)
+		default:
+			return events, false
+		}
+	}
+}
+
+func changeTypes(events []ChangeEvent) []ChangeType {
+	types := make([]ChangeType, len(events))
+	for i, ev := range events {
+		types[i] = ev.Type
+	}
+	return types
+}
+
+func TestStore_Watch(t *testing.T) {
+	s := NewStore()
+	all, err := s.Watch(t.Context(), WatchOptions{})
+	require.NoError(t, err)
+
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, users.Put(userFields("u1", map[string]any{"age": 30})))
+	require.NoError(t, users.Put(userFields("u1", map[string]any{"age": 31})))
+	_, err = users.Update("u1", Inc("age", 1))
+	require.NoError(t, err)
+	require.NoError(t, users.Delete("u1"))
+	require.NoError(t, s.DeleteCollection("users"))
+
+	events, closed := drain(all)
+	assert.False(t, closed)
+	require.Equal(t, []ChangeType{ChangeCreate, ChangeInsert, ChangeReplace, ChangeUpdate, ChangeDelete, ChangeDrop}, changeTypes(events))
+	for i, ev := range events {
+		assert.Equal(t, uint64(i+1), ev.Sequence)
+		assert.Equal(t, "users", ev.Collection)
+	}
+	insert, update, del := events[1], events[3], events[4]
+	assert.Equal(t, "u1", insert.Key)
+	assert.Nil(t, insert.Before)
+	assert.Equal(t, 31, update.Before.Fields["age"].Value)
+	assert.Equal(t, 32, update.After.Fields["age"].Value)
+	assert.Nil(t, del.After)
+	assert.Equal(t, update.After, del.Before)
+
+	t.Run("rejected writes are not reported", func(t *testing.T) {
+		orders, err := s.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id", Unique: []UniqueConstraint{{Fields: []string{"email"}}}})
+		require.NoError(t, err)
+		require.NoError(t, orders.Put(userFields("o1", nil)))
+		other := userFields("o2", nil)
+		other.Fields["email"] = orders.List()[0].Fields["email"]
+		require.Error(t, orders.Put(other))
+		events, _ := drain(all)
+		assert.Equal(t, []ChangeType{ChangeCreate, ChangeInsert}, changeTypes(events))
+	})
+}
+
+func TestCollection_Watch(t *testing.T) {
+	s := NewStore()
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	orders, err := s.CreateCollection("orders", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	filter := Gte("age", 18)
+	adults, err := users.Watch(t.Context(), WatchOptions{Filter: &filter})
+	require.NoError(t, err)
+
+	require.NoError(t, users.Put(userFields("u1", map[string]any{"age": 10})))
+	require.NoError(t, users.Put(userFields("u2", map[string]any{"age": 20})))
+	require.NoError(t, orders.Put(userFields("o1", map[string]any{"age": 20})))
+	require.NoError(t, users.Delete("u2"))
+	require.NoError(t, s.DeleteCollection("users"))
+
+	events, closed := drain(adults)
+	assert.True(t, closed, "closed after the collection is dropped")
+	assert.Equal(t, []ChangeType{ChangeInsert, ChangeDelete, ChangeDrop}, changeTypes(events))
+	assert.Equal(t, "u2", events[0].Key)
+
+	bad := Filter{Op: "$like"}
+	_, err = orders.Watch(t.Context(), WatchOptions{Filter: &bad})
+	assert.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestWatch_Tx(t *testing.T) {
+	s := NewStore()
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	require.NoError(t, users.Put(userFields("u1", map[string]any{"age": 1})))
+	ch, err := users.Watch(t.Context(), WatchOptions{})
+	require.NoError(t, err)
+
+	err = s.Tx(func(tx *Tx) error {
+		require.NoError(t, tx.Put("users", userFields("u2", nil)))
+		return errors.New("abort")
+	})
+	require.Error(t, err)
+	events, _ := drain(ch)
+	assert.Empty(t, events, "rolled back writes are not reported")
+
+	require.NoError(t, s.Tx(func(tx *Tx) error {
+		if _, err := tx.Update("users", "u1", Inc("age", 1)); err != nil {
+			return err
+		}
+		return tx.Put("users", userFields("u2", nil))
+	}))
+	events, _ = drain(ch)
+	assert.Equal(t, []ChangeType{ChangeUpdate, ChangeInsert}, changeTypes(events))
+}
+
+func TestWatch_Resume(t *testing.T) {
+	s := NewStore()
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	for _, id := range []string{"u1", "u2", "u3"} {
+		require.NoError(t, users.Put(userFields(id, nil)))
+	}
+
+	// 1 is the creation of the collection
+	ch, err := s.Watch(t.Context(), WatchOptions{ResumeAfter: 3})
+	require.NoError(t, err)
+	require.NoError(t, users.Put(userFields("u4", nil)))
+	events, _ := drain(ch)
+	require.Len(t, events, 2)
+	assert.Equal(t, "u3", events[0].Key)
+	assert.Equal(t, "u4", events[1].Key)
+
+	_, err = s.Watch(t.Context(), WatchOptions{ResumeAfter: 100})
+	assert.ErrorIs(t, err, ErrResumeTokenExpired)
+
+	for range changeHistorySize {
+		require.NoError(t, users.Put(userFields("u1", nil)))
+	}
+	_, err = s.Watch(t.Context(), WatchOptions{ResumeAfter: 2})
+	assert.ErrorIs(t, err, ErrResumeTokenExpired)
+}
+
+func TestWatch_SlowReceiver(t *testing.T) {
+	s := NewStore()
+	users, err := s.CreateCollection("users", &CollectionConfig{PrimaryKey: "id"})
+	require.NoError(t, err)
+	slow, err := users.Watch(t.Context(), WatchOptions{BufferSize: 2})
+	require.NoError(t, err)
+
+	for _, id := range []string{"u1", "u2", "u3", "u4"} {
+		require.NoError(t, users.Put(userFields(id, nil)), "writers do not wait")
+	}
+	events, closed := drain(slow)
+	assert.True(t, closed)
+	require.Equal(t, []ChangeType{ChangeInsert, ChangeInsert, ChangeLagged}, changeTypes(events))
+
+	resumed, err := users.Watch(t.Context(), WatchOptions{ResumeAfter: events[2].Sequence})
+	require.NoError(t, err)
+	events, _ = drain(resumed)
+	require.Len(t, events, 2)
+	assert.Equal(t, "u3", events[0].Key)
+	assert.Equal(t, "u4", events[1].Key)
+}
+
+func TestWatch_Closed(t *testing.T) {
+	s := NewStore()
+	ctx, cancel := context.WithCancel(t.Context())
+	byCtx, err := s.Watch(ctx, WatchOptions{})
+	require.NoError(t, err)
+	byClose, err := s.Watch(t.Context(), WatchOptions{})
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case _, ok := <-byCtx:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel is not closed after the context is canceled")
+	}
+
+	require.NoError(t, s.Close())
+	_, ok := <-byClose
+	assert.False(t, ok)
+}